@@ -3,12 +3,16 @@ module github.com/seniorGolang/gokit
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.6
+	github.com/fxamacker/cbor/v2 v2.2.0
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/go-kit/kit v0.10.0
 	github.com/gorilla/mux v1.7.4
+	github.com/gorilla/websocket v1.4.2
 	github.com/opentracing/opentracing-go v1.1.0
 	github.com/openzipkin/zipkin-go v0.2.2
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/valyala/fasthttp v1.9.0
+	github.com/vmihailenco/msgpack v4.0.4+incompatible
 )
@@ -0,0 +1,205 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+const (
+	claimsKey    contextKey = "claims"
+	authErrorKey contextKey = "authError"
+
+	// ownerHeader is the header of the owner id of calls without authenticator.
+	ownerHeader = "X-User-Id"
+)
+
+var errUnknownToken = errors.New("unknown token")
+
+// Claims are verified claims of the caller.
+type Claims struct {
+	// Subject is the id of the caller, which is returned by utils.GetOwnerId.
+	Subject   string
+	SessionID string
+	// Permissions are checked against permissions declared for methods
+	// with ServerPermissions.
+	Permissions []string
+	ExpiresAt   time.Time
+	// Extra holds all claims of the token, if any.
+	Extra map[string]interface{}
+}
+
+// HasPermission reports whether the caller is granted the permission.
+func (c Claims) HasPermission(permission string) bool {
+
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies the bearer token of a request and returns claims of the caller.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Claims, error)
+}
+
+// AuthenticatorFunc is an adapter to use ordinary functions as Authenticator.
+type AuthenticatorFunc func(ctx context.Context, token string) (Claims, error)
+
+// Authenticate calls f(ctx, token).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token string) (Claims, error) {
+	return f(ctx, token)
+}
+
+// StaticTokens authenticates callers by tokens known in advance,
+// e.g. tokens of internal services.
+func StaticTokens(tokens map[string]Claims) Authenticator {
+
+	return AuthenticatorFunc(func(_ context.Context, token string) (Claims, error) {
+
+		// all tokens are compared to not leak the matching one by timing
+		var claims Claims
+		var found bool
+
+		for known, knownClaims := range tokens {
+			if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+				claims, found = knownClaims, true
+			}
+		}
+
+		if !found {
+			return Claims{}, errUnknownToken
+		}
+		return claims, nil
+	})
+}
+
+// Authenticators tries authenticators in order and returns claims of the
+// first one accepting the token, e.g. static tokens of services and JWT of users.
+func Authenticators(authenticators ...Authenticator) Authenticator {
+
+	return AuthenticatorFunc(func(ctx context.Context, token string) (claims Claims, err error) {
+
+		err = errUnknownToken
+
+		for _, authenticator := range authenticators {
+			if claims, err = authenticator.Authenticate(ctx, token); err == nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// ServerAuthenticator verifies bearer tokens of the Authorization header with
+// the authenticator. Once it is set, all methods require an authenticated
+// caller except those declared by ServerPublicMethods, and the owner returned
+// by utils.GetOwnerId is the subject of the verified token instead of the
+// x-user-id header.
+func ServerAuthenticator(authenticator Authenticator) ServerOption {
+	return func(s *Server) { s.authenticator = authenticator }
+}
+
+// ServerPermissions declares permissions required to call the method,
+// the caller must be granted all of them.
+func ServerPermissions(method string, permissions ...string) ServerOption {
+	return func(s *Server) {
+		if s.permissions == nil {
+			s.permissions = make(map[string][]string)
+		}
+		s.permissions[method] = append(s.permissions[method], permissions...)
+	}
+}
+
+// ServerPublicMethods declares methods callable without authentication.
+func ServerPublicMethods(methods ...string) ServerOption {
+	return func(s *Server) {
+		if s.publicMethods == nil {
+			s.publicMethods = make(map[string]bool)
+		}
+		for _, method := range methods {
+			s.publicMethods[method] = true
+		}
+	}
+}
+
+// ClaimsFromContext returns claims of the authenticated caller.
+func ClaimsFromContext(ctx context.Context) (claims Claims, found bool) {
+	claims, found = ctx.Value(claimsKey).(Claims)
+	return
+}
+
+// authenticate verifies the token of the Authorization header and stores
+// the outcome in the context. Requests without token are anonymous.
+// Without authenticator the owner is taken from the X-User-Id header.
+func (s Server) authenticate(ctx context.Context, authorization, owner string) context.Context {
+
+	if s.authenticator == nil {
+		// owners set by before functions or ServerTracing are kept
+		if owner != "" && utils.GetOwnerId(ctx) == "" {
+			ctx = utils.SetOwnerId(ctx, owner)
+		}
+		return ctx
+	}
+
+	// the owner is trusted only if it comes from the verified token
+	ctx = utils.SetOwnerId(ctx, "")
+
+	token := bearerToken(authorization)
+
+	if token == "" {
+		return ctx
+	}
+
+	claims, err := s.authenticator.Authenticate(ctx, token)
+
+	if err != nil {
+		return context.WithValue(ctx, authErrorKey, err)
+	}
+
+	ctx = context.WithValue(ctx, claimsKey, claims)
+	return utils.SetOwnerId(ctx, claims.Subject)
+}
+
+// authorize checks that the caller may call the method.
+func (s Server) authorize(ctx context.Context, method string) error {
+
+	if s.authenticator == nil || s.publicMethods[method] {
+		return nil
+	}
+
+	if err, _ := ctx.Value(authErrorKey).(error); err != nil {
+		return unauthorizedError("invalid token: " + err.Error())
+	}
+
+	claims, found := ClaimsFromContext(ctx)
+
+	if !found {
+		return unauthorizedError("authentication required")
+	}
+
+	for _, permission := range s.permissions[method] {
+		if !claims.HasPermission(permission) {
+			return forbiddenError(fmt.Sprintf("permission %s required", permission))
+		}
+	}
+	return nil
+}
+
+// bearerToken returns the token of the Authorization header value.
+func bearerToken(authorization string) string {
+
+	const prefix = "bearer "
+
+	if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(authorization[len(prefix):])
+}
@@ -0,0 +1,367 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// serveBody decodes the request or the batch of requests from data and
+// executes them. Returned batch reports whether responses must be sent as an
+// array. Invalid JSON is reported with the parse error.
+func (s Server) serveBody(ctx context.Context, urlMethod string, data []byte) (batch bool, respList []Response, err error) {
+
+	codec := messageCodec(ctx)
+
+	// binary messages are served as equal JSON ones, so limits and semantics
+	// of requests are the same
+	if binary, ok := codec.(BinaryCodec); ok {
+		if err = s.checkBinary(binary, data); err != nil {
+			return false, nil, err
+		}
+		var raw json.RawMessage
+		if err = binary.Unmarshal(data, &raw); err != nil {
+			return false, nil, parseError("request body could not be decoded: " + err.Error())
+		}
+		data, codec = raw, binary.JSON()
+		ctx = context.WithValue(ctx, codecKey, codec)
+	}
+
+	if err = s.checkBody(data); err != nil {
+		return false, nil, err
+	}
+
+	switch firstByte(data) {
+
+	case '[':
+		var reqList []json.RawMessage
+		var errResp *Response
+		if reqList, errResp, err = s.decodeBatch(codec, data); err != nil {
+			return false, nil, err
+		} else if errResp != nil {
+			return false, append(respList, *errResp), nil
+		}
+		return true, s.serveBatch(ctx, urlMethod, reqList), nil
+
+	case '{':
+		// a single request is decoded in place to avoid the second pass
+		var req Request
+		if err = codec.Unmarshal(data, &req); err != nil && !json.Valid(data) {
+			return false, nil, parseError("request body could not be decoded: " + err.Error())
+		}
+		if resp := s.serveDecoded(ctx, urlMethod, req, err); resp != nil {
+			respList = append(respList, *resp)
+		}
+		return false, respList, nil
+	}
+
+	if !json.Valid(data) {
+		return false, nil, parseError("request body could not be decoded: invalid JSON")
+	}
+	return false, append(respList, invalidRequest("request must be an object or an array")), nil
+}
+
+// decodeBatch splits the batch into requests. The batch rejected as a whole
+// is answered with errResp.
+func (s Server) decodeBatch(codec Codec, data []byte) (reqList []json.RawMessage, errResp *Response, err error) {
+
+	if err = codec.Unmarshal(data, &reqList); err != nil {
+		return nil, nil, parseError("request body could not be decoded: " + err.Error())
+	}
+
+	errResp = new(Response)
+
+	if len(reqList) == 0 {
+		*errResp = invalidRequest("empty batch")
+		return nil, errResp, nil
+	}
+
+	if s.batchLimit > 0 && len(reqList) > s.batchLimit {
+		*errResp = invalidRequest(fmt.Sprintf("batch size %d exceeds limit %d", len(reqList), s.batchLimit))
+		return nil, errResp, nil
+	}
+	return reqList, nil, nil
+}
+
+// serveBatch executes requests of the batch and returns the responses in
+// the order of the requests. Notifications produce no response, so the
+// result may be shorter than reqList.
+func (s Server) serveBatch(ctx context.Context, urlMethod string, reqList []json.RawMessage) (respList []Response) {
+
+	results := make([]*Response, len(reqList))
+
+	s.executeBatch(ctx, urlMethod, reqList, func(i int, resp *Response) { results[i] = resp })
+
+	for _, resp := range results {
+		if resp != nil {
+			respList = append(respList, *resp)
+		}
+	}
+	return
+}
+
+// executeBatch executes requests of the batch on a pool of at most
+// batchParallel goroutines (one per request when unlimited) and passes
+// the response of each request to emit as soon as it is ready. emit is
+// called concurrently, with nil responses for notifications.
+func (s Server) executeBatch(ctx context.Context, urlMethod string, reqList []json.RawMessage, emit func(i int, resp *Response)) {
+
+	methods := make([]string, len(reqList))
+
+	workers := len(reqList)
+
+	if s.batchParallel > 0 && s.batchParallel < workers {
+		workers = s.batchParallel
+	}
+
+	serve := func(i int) {
+		var resp *Response
+		resp, methods[i] = s.serveRequest(ctx, urlMethod, reqList[i])
+		emit(i, resp)
+	}
+
+	if workers <= 1 {
+		for i := range reqList {
+			serve(i)
+		}
+	} else {
+
+		var wg sync.WaitGroup
+
+		queue := make(chan int)
+
+		for w := 0; w < workers; w++ {
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				for i := range queue {
+					serve(i)
+				}
+			}()
+		}
+
+		for i := range reqList {
+			queue <- i
+		}
+		close(queue)
+		wg.Wait()
+	}
+
+	if s.metrics != nil {
+		for i := range methods {
+			methods[i] = s.methodLabel(methods[i])
+		}
+		s.metrics.observeBatch(serverSide, methods)
+	}
+}
+
+// serveRequest decodes, validates and executes a single request of the batch
+// with its own context. It returns nil when no response must be sent back to
+// the client and the method of the request.
+func (s Server) serveRequest(ctx context.Context, urlMethod string, reqData json.RawMessage) (resp *Response, method string) {
+
+	if firstByte(reqData) != '{' {
+		resp = new(Response)
+		*resp = invalidRequest("request must be an object")
+		return
+	}
+
+	var req Request
+	err := messageCodec(ctx).Unmarshal(reqData, &req)
+
+	if method = req.Method; urlMethod != "" {
+		method = urlMethod
+	}
+	return s.serveDecoded(ctx, urlMethod, req, err), method
+}
+
+// serveDecoded validates and executes the decoded request.
+// decodeErr is the error of decoding, which makes the request invalid.
+func (s Server) serveDecoded(ctx context.Context, urlMethod string, req Request, decodeErr error) (resp *Response) {
+
+	if decodeErr != nil {
+		resp = new(Response)
+		*resp = invalidRequest("request could not be decoded: " + decodeErr.Error())
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		resp = new(Response)
+		*resp = invalidRequest(err.Error())
+		resp.ID = req.ID
+		return
+	}
+
+	ctx = context.WithValue(ctx, reqID, req.ID)
+
+	if s.strictParams {
+		ctx = context.WithValue(ctx, strictParamsKey, true)
+	}
+
+	// notifications are executed as calls, their responses are dropped
+	// after the outcome is recorded
+	defer func() {
+		if req.ID == nil {
+			resp = nil
+		}
+	}()
+
+	if urlMethod != "" && req.Method != "" && req.Method != urlMethod {
+		return errorResponse(req.ID, MethodNotFoundError, fmt.Sprintf("incorrect method: %s != %s", urlMethod, req.Method))
+	}
+
+	if urlMethod != "" {
+		req.Method = urlMethod
+	}
+
+	if s.metrics != nil {
+		defer func(started time.Time) {
+			s.metrics.observeCall(serverSide, s.methodLabel(req.Method), responseCode(resp), time.Since(started))
+		}(time.Now())
+	}
+
+	return s.call(ctx, req)
+}
+
+// call executes the request. It returns the response even for notifications,
+// the response of a successful notification has no result.
+func (s Server) call(ctx context.Context, req Request) (resp *Response) {
+
+	if s.tracing {
+		var span opentracing.Span
+		ctx, span = startSpan(ctx, req)
+		defer func(started time.Time) { finishServerSpan(span, resp, started) }(time.Now())
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("method", req.Method).Errorf("panic: %v", r)
+			resp = s.endpointError(ctx, req.ID, internalError(fmt.Sprintf("panic: %v", r)))
+		}
+	}()
+
+	ecm, ok := s.ecm[req.Method]
+
+	if !ok {
+		return errorResponse(req.ID, MethodNotFoundError, fmt.Sprintf("method %s not found", req.Method))
+	}
+
+	if err := s.authorize(ctx, req.Method); err != nil {
+		return s.endpointError(ctx, req.ID, err)
+	}
+
+	release, err := s.acquire(ctx, req.Method)
+
+	if err != nil {
+		return s.endpointError(ctx, req.ID, err)
+	}
+
+	defer release()
+
+	reqParams, err := ecm.Decode(ctx, req.Params)
+
+	if err != nil {
+		return errorResponse(req.ID, InvalidParamsError, fmt.Sprintf("decode params error: %s", err.Error()))
+	}
+
+	response, err := s.handler(ctx, &Call{
+		Method:   req.Method,
+		ID:       req.ID,
+		Params:   reqParams,
+		endpoint: ecm.Endpoint,
+	})
+
+	if err != nil {
+		return s.endpointError(ctx, req.ID, err)
+	}
+
+	if req.ID == nil {
+		return &Response{JSONRPC: Version}
+	}
+
+	result, err := ecm.Encode(ctx, response)
+
+	if err != nil {
+		return s.endpointError(ctx, req.ID, internalError(fmt.Sprintf("response encode error: %s", err.Error())))
+	}
+
+	return &Response{
+		ID:      req.ID,
+		JSONRPC: Version,
+		Result:  result,
+	}
+}
+
+// endpointError builds an error response from the error returned by endpoint.
+func (s Server) endpointError(ctx context.Context, id *RequestID, err error) *Response {
+	return &Response{
+		ID:      id,
+		JSONRPC: Version,
+		Error:   s.rpcError(ctx, err),
+	}
+}
+
+// errorResponse builds an error response for the request with given id.
+func errorResponse(id *RequestID, code int, message string) *Response {
+	return &Response{
+		ID:      id,
+		JSONRPC: Version,
+		Error: &Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// bodyError builds the response for errors of the request body as a whole.
+func bodyError(err error) Response {
+	return Response{
+		JSONRPC: Version,
+		Error:   codedError(err, ParseError),
+	}
+}
+
+// encodeResponses encodes responses of the body with the codec,
+// the body of notifications has no responses to encode.
+func encodeResponses(codec Codec, batch bool, respList []Response) ([]byte, error) {
+
+	if batch && len(respList) > 0 {
+		return codec.Marshal(respList)
+	}
+
+	if len(respList) == 1 {
+		return codec.Marshal(respList[0])
+	}
+	return nil, nil
+}
+
+// invalidRequest builds an InvalidRequestError response, which is sent back
+// even if the request id could not be determined.
+func invalidRequest(message string) Response {
+	return Response{
+		JSONRPC: Version,
+		Error: &Error{
+			Code:    InvalidRequestError,
+			Message: message,
+		},
+	}
+}
+
+// firstByte returns the first non-whitespace byte of JSON data.
+func firstByte(data []byte) byte {
+
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c
+	}
+	return 0
+}
@@ -0,0 +1,203 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testParams struct {
+	A     int `json:"a"`
+	Delay int `json:"delay,omitempty"`
+}
+
+type testService struct {
+	running int32
+	peak    int32
+}
+
+func (s *testService) Echo(_ context.Context, p testParams) (int, error) {
+
+	running := atomic.AddInt32(&s.running, 1)
+	defer atomic.AddInt32(&s.running, -1)
+
+	for peak := atomic.LoadInt32(&s.peak); running > peak; peak = atomic.LoadInt32(&s.peak) {
+		if atomic.CompareAndSwapInt32(&s.peak, peak, running) {
+			break
+		}
+	}
+
+	time.Sleep(time.Duration(p.Delay) * time.Millisecond)
+	return p.A, nil
+}
+
+func (s *testService) Fail(_ context.Context, p testParams) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func (s *testService) Panic(_ context.Context, p testParams) (int, error) {
+	panic("boom")
+}
+
+type testResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func newTestServer(t *testing.T, options ...ServerOption) (*Server, *testService) {
+
+	svc := new(testService)
+	ecm, err := MakeEndpointCodecMap("", svc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(ecm, options...), svc
+}
+
+// serve posts the body to the handler and returns the response body.
+func serve(t *testing.T, h http.Handler, body string) string {
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func decodeBatch(t *testing.T, body string) (respList []testResponse) {
+
+	if err := json.Unmarshal([]byte(body), &respList); err != nil {
+		t.Fatalf("batch response %q: %v", body, err)
+	}
+	return
+}
+
+func decodeSingle(t *testing.T, body string) (resp testResponse) {
+
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("response %q: %v", body, err)
+	}
+	return
+}
+
+func TestBatchOrder(t *testing.T) {
+
+	s, svc := newTestServer(t)
+
+	const size = 10
+
+	var batch bytes.Buffer
+	batch.WriteByte('[')
+
+	for i := 0; i < size; i++ {
+		if i > 0 {
+			batch.WriteByte(',')
+		}
+		// later requests complete first
+		fmt.Fprintf(&batch, `{"jsonrpc":"2.0","method":"echo","params":{"a":%d,"delay":%d},"id":%d}`, i, (size-i)*5, i)
+	}
+	batch.WriteByte(']')
+
+	respList := decodeBatch(t, serve(t, s, batch.String()))
+
+	if len(respList) != size {
+		t.Fatalf("got %d responses, want %d", len(respList), size)
+	}
+
+	for i, resp := range respList {
+		if string(resp.ID) != fmt.Sprint(i) || string(resp.Result) != fmt.Sprint(i) || resp.Error != nil {
+			t.Errorf("response %d: id %s, result %s, error %v", i, resp.ID, resp.Result, resp.Error)
+		}
+	}
+
+	if atomic.LoadInt32(&svc.peak) < 2 {
+		t.Errorf("batch was not executed concurrently, peak %d", svc.peak)
+	}
+}
+
+func TestBatchItemErrors(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	body := serve(t, s, `[
+		{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":1},
+		{"jsonrpc":"2.0","method":"panic","params":{},"id":2},
+		{"jsonrpc":"2.0","method":"echo","params":{"a":3}},
+		{"jsonrpc":"2.0","method":"fail","params":{},"id":4},
+		{"jsonrpc":"2.0","method":"missing","id":5}
+	]`)
+
+	respList := decodeBatch(t, body)
+
+	want := []struct {
+		id     string
+		result string
+		code   int
+	}{
+		{"1", "1", 0},
+		{"2", "", InternalError},
+		{"4", "", InternalError},
+		{"5", "", MethodNotFoundError},
+	}
+
+	if len(respList) != len(want) {
+		t.Fatalf("got %d responses, want %d: %s", len(respList), len(want), body)
+	}
+
+	for i, w := range want {
+
+		resp := respList[i]
+
+		if string(resp.ID) != w.id {
+			t.Errorf("response %d: id %s, want %s", i, resp.ID, w.id)
+		}
+
+		if w.code == 0 && (resp.Error != nil || string(resp.Result) != w.result) {
+			t.Errorf("response %d: result %s, error %v", i, resp.Result, resp.Error)
+		}
+
+		if w.code != 0 && (resp.Error == nil || resp.Error.Code != w.code) {
+			t.Errorf("response %d: error %v, want code %d", i, resp.Error, w.code)
+		}
+	}
+}
+
+func TestBatchNotificationsOnly(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	body := serve(t, s, `[{"jsonrpc":"2.0","method":"echo","params":{"a":1}},{"jsonrpc":"2.0","method":"fail","params":{}}]`)
+
+	if body != "" {
+		t.Errorf("unexpected response to notifications: %s", body)
+	}
+}
+
+func TestBatchParallelism(t *testing.T) {
+
+	s, svc := newTestServer(t, ServerBatchParallelism(2))
+
+	serve(t, s, `[
+		{"jsonrpc":"2.0","method":"echo","params":{"a":1,"delay":10},"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":{"a":2,"delay":10},"id":2},
+		{"jsonrpc":"2.0","method":"echo","params":{"a":3,"delay":10},"id":3},
+		{"jsonrpc":"2.0","method":"echo","params":{"a":4,"delay":10},"id":4}
+	]`)
+
+	if atomic.LoadInt32(&svc.peak) > 2 {
+		t.Errorf("peak concurrency %d exceeds limit 2", svc.peak)
+	}
+}
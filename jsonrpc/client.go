@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/opentracing/opentracing-go"
 	"github.com/valyala/fasthttp"
 
 	"github.com/seniorGolang/gokit/types/uuid"
@@ -19,14 +21,33 @@ type ClientResponseFunc func(context.Context, *fasthttp.Response) context.Contex
 type Client struct {
 	method string
 	tgtURL *url.URL
-	client fasthttp.Client
+	client *fasthttp.Client
 
 	enc        EncodeRequestFunc
 	dec        DecodeResponseFunc
 	before     []ClientRequestFunc
 	after      []ClientResponseFunc
 	errDecoder DecodeResponseError
+	errors     *ErrorRegistry
 	requestID  RequestIDGenerator
+
+	timeout  time.Duration
+	retry    *retryPolicy
+	breaker  *circuitBreaker
+	balancer Balancer
+
+	ws *WSClient
+
+	metrics *Metrics
+
+	codec Codec
+
+	compression          string
+	compressionThreshold int
+
+	noPropagation bool
+	allowHeaders  map[string]bool
+	denyHeaders   map[string]bool
 }
 
 func NewClient(uri, method string, options ...ClientOption) *Client {
@@ -36,10 +57,11 @@ func NewClient(uri, method string, options ...ClientOption) *Client {
 	c := &Client{
 		method:    method,
 		tgtURL:    tgtURL,
-		client:    fasthttp.Client{},
+		client:    &fasthttp.Client{},
 		requestID: NewUUIDGenerator(),
 		enc:       DefaultRequestEncoder,
 		dec:       DefaultResponseDecoder,
+		codec:     DefaultCodec,
 	}
 
 	for _, option := range options {
@@ -48,16 +70,16 @@ func NewClient(uri, method string, options ...ClientOption) *Client {
 	return c
 }
 
-func DefaultRequestEncoder(_ context.Context, req interface{}) (json.RawMessage, error) {
-	return json.Marshal(req)
+func DefaultRequestEncoder(ctx context.Context, req interface{}) (json.RawMessage, error) {
+	return CodecFromContext(ctx).Marshal(req)
 }
 
-func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error) {
+func DefaultResponseDecoder(ctx context.Context, res Response) (interface{}, error) {
 	if res.Error != nil {
 		return nil, *res.Error
 	}
 	var result interface{}
-	err := json.Unmarshal(res.Result, &result)
+	err := CodecFromContext(ctx).Unmarshal(res.Result, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +88,7 @@ func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error
 
 type ClientOption func(*Client)
 
-func (c Client) Endpoint() endpoint.Endpoint {
+func (c *Client) Endpoint() endpoint.Endpoint {
 
 	return func(ctx context.Context, request interface{}) (result interface{}, err error) {
 
@@ -74,8 +96,7 @@ func (c Client) Endpoint() endpoint.Endpoint {
 		ctx, cancel = context.WithCancel(ctx)
 		defer cancel()
 
-		req := fasthttp.AcquireRequest()
-		resp := fasthttp.AcquireResponse()
+		ctx = context.WithValue(ctx, codecKey, c.codec)
 
 		var params json.RawMessage
 		if params, err = c.enc(ctx, request); err != nil {
@@ -89,46 +110,201 @@ func (c Client) Endpoint() endpoint.Endpoint {
 			ID:      c.requestID.Generate(),
 		}
 
-		req.Header.SetMethod("POST")
-		req.SetRequestURI(c.tgtURL.String() + "/" + c.method)
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-
-		if err = json.NewEncoder(req.BodyWriter()).Encode(rpcReq); err != nil {
-			return
-		}
+		var rpcResRaw ResponseRaw
 
-		for _, f := range c.before {
-			ctx = f(ctx, req)
+		if c.metrics != nil {
+			defer func(started time.Time) {
+				c.metrics.observeCall(clientSide, c.method, callCode(rpcResRaw.Error, err), time.Since(started))
+			}(time.Now())
 		}
 
-		if err = c.client.Do(req, resp); err != nil {
+		var body []byte
+		if ctx, body, err = c.call(ctx, "/"+c.method, rpcReq); err != nil {
 			return
 		}
 
 		// Decode the body into an object
-		var rpcResRaw ResponseRaw
-		if err = json.Unmarshal(resp.Body(), &rpcResRaw); err != nil {
+		if err = c.codec.Unmarshal(body, &rpcResRaw); err != nil {
 			return
 		}
+		return c.decodeResponse(ctx, rpcResRaw, c.dec)
+	}
+}
+
+// Notify sends the request as a notification, which has no id,
+// so the server sends no response back.
+func (c *Client) Notify(ctx context.Context, request interface{}) (err error) {
+
+	ctx = context.WithValue(ctx, codecKey, c.codec)
+
+	var params json.RawMessage
+	if params, err = c.enc(ctx, request); err != nil {
+		return
+	}
+
+	rpcReq := Request{
+		JSONRPC: Version,
+		Params:  params,
+		Method:  c.method,
+	}
+
+	if c.metrics != nil {
+		defer func(started time.Time) {
+			c.metrics.observeCall(clientSide, c.method, callCode(nil, err), time.Since(started))
+		}(time.Now())
+	}
+
+	_, _, err = c.call(ctx, "/"+c.method, rpcReq)
+	return
+}
+
+// call posts JSON RPC payload to the path of the target and returns the response body.
+func (c *Client) call(ctx context.Context, path string, payload interface{}) (_ context.Context, body []byte, err error) {
+
+	if c.ws != nil {
+		body, err = c.ws.call(ctx, c.deadline(ctx), c.codec, payload)
+		return ctx, body, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod("POST")
+	req.SetRequestURI(c.tgtURL.String() + path)
+
+	// propagated headers go first, so they never override headers of the client
+	var span opentracing.Span
+	ctx, span = c.propagate(ctx, path, payload, req)
+	defer func() { finishSpan(span, c.codec, err, resp) }()
+
+	req.Header.Set("Content-Type", c.codec.ContentType())
+	req.Header.Set("Accept", c.codec.ContentType())
+
+	var data []byte
+	if data, err = c.codec.Marshal(payload); err != nil {
+		return ctx, nil, err
+	}
+
+	if err = c.compressRequest(req, data); err != nil {
+		return ctx, nil, err
+	}
+
+	for _, f := range c.before {
+		ctx = f(ctx, req)
+	}
+
+	deadline := c.deadline(ctx)
+
+	for attempt := 0; ; attempt++ {
+
+		if err = ctx.Err(); err != nil {
+			return ctx, nil, err
+		}
+
+		if err = c.breaker.allow(); err != nil {
+			return ctx, nil, err
+		}
 
-		if rpcResRaw.Error != nil && c.errDecoder != nil {
-			if err = c.errDecoder(ctx, rpcResRaw.Error); err != nil {
-				return
+		var target string
+
+		if c.balancer != nil {
+			if target, err = c.balancer.Pick(); err != nil {
+				c.breaker.cancel()
+				return ctx, nil, err
 			}
+			req.SetRequestURI(target + path)
+		}
+
+		if deadline.IsZero() {
+			err = c.client.Do(req, resp)
+		} else {
+			err = c.client.DoDeadline(req, resp, deadline)
 		}
 
-		for _, f := range c.after {
-			ctx = f(ctx, resp)
+		if err == nil {
+			err = c.decompressResponse(resp)
 		}
 
-		rpcRes := Response{
-			ID:      rpcResRaw.ID,
-			Result:  rpcResRaw.Result,
-			JSONRPC: rpcResRaw.JSONRPC,
+		if err == nil {
+			err = statusError(c.codec, resp)
+		}
+
+		// error statuses without a JSON RPC error fail by statusError, errors
+		// of the endpoint reach the caller unless their codes are retryable
+		failed := err != nil || c.retry.retryable(c.codec, resp.Body())
+		c.breaker.done(!failed)
+
+		if c.balancer != nil {
+			c.balancer.Done(target, failed)
 		}
 
-		return c.dec(ctx, rpcRes)
+		if !failed || !c.retry.wait(ctx, attempt, deadline) {
+			break
+		}
+		resp.Reset()
+	}
+
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	for _, f := range c.after {
+		ctx = f(ctx, resp)
 	}
+
+	body = append(body, resp.Body()...)
+	return ctx, body, nil
+}
+
+// deadline returns the earliest of the context deadline and the client timeout.
+func (c *Client) deadline(ctx context.Context) (deadline time.Time) {
+
+	deadline, _ = ctx.Deadline()
+
+	if c.timeout > 0 {
+		if timeout := time.Now().Add(c.timeout); deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+	return
+}
+
+// decodeResponse decodes the result or the error of the response.
+func (c *Client) decodeResponse(ctx context.Context, rpcResRaw ResponseRaw, dec DecodeResponseFunc) (result interface{}, err error) {
+
+	if rpcResRaw.Error != nil && c.errDecoder != nil {
+		if err = c.errDecoder(ctx, rpcResRaw.Error); err != nil {
+			return
+		}
+	}
+
+	rpcRes := Response{
+		ID:      rpcResRaw.ID,
+		Result:  rpcResRaw.Result,
+		JSONRPC: rpcResRaw.JSONRPC,
+	}
+
+	if rpcResRaw.Error != nil {
+
+		codec := CodecFromContext(ctx)
+
+		var rpcErr errorRaw
+		if err = codec.Unmarshal(rpcResRaw.Error, &rpcErr); err != nil {
+			return
+		}
+
+		if c.errors != nil {
+			return nil, c.errors.decode(codec, rpcErr)
+		}
+
+		rpcRes.Error = new(Error)
+		*rpcRes.Error = rpcErr.toError(codec)
+	}
+
+	return dec(ctx, rpcRes)
 }
 
 func SetAuthorizationHeader(token string) ClientRequestFunc {
@@ -154,7 +330,7 @@ func SetSessionIDHeader(sessionID uuid.UUID) ClientRequestFunc {
 }
 
 func SetClient(client fasthttp.Client) ClientOption {
-	return func(c *Client) { c.client = client }
+	return func(c *Client) { c.client = &client }
 }
 
 func ClientBefore(before ...ClientRequestFunc) ClientOption {
@@ -169,10 +345,27 @@ func ClientRequestEncoder(enc EncodeRequestFunc) ClientOption {
 	return func(c *Client) { c.enc = enc }
 }
 
+// ClientParamsByPosition encodes request params as an array of struct
+// fields ordered by their positions, see EncodeParams.
+func ClientParamsByPosition() ClientOption {
+	return func(c *Client) { c.enc = PositionalRequestEncoder }
+}
+
+// ClientParamsByName encodes request params as an object, which is the default.
+func ClientParamsByName() ClientOption {
+	return func(c *Client) { c.enc = DefaultRequestEncoder }
+}
+
 func ClientResponseErrorDecoder(enc DecodeResponseError) ClientOption {
 	return func(c *Client) { c.errDecoder = enc }
 }
 
+// ClientErrorRegistry decodes JSON RPC errors returned by the server to Go
+// errors registered in the registry. Unregistered codes are returned as Error.
+func ClientErrorRegistry(registry *ErrorRegistry) ClientOption {
+	return func(c *Client) { c.errors = registry }
+}
+
 func ClientResponseDecoder(dec DecodeResponseFunc) ClientOption {
 	return func(c *Client) { c.dec = dec }
 }
@@ -0,0 +1,152 @@
+package jsonrpc
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoTargets is returned by balancers when discovery returns no targets.
+var ErrNoTargets = errors.New("no targets available")
+
+// Discoverer provides the current set of target URLs of a service.
+// Implementations are called on every pick, so they should cache results
+// of expensive lookups.
+type Discoverer interface {
+	Targets() ([]string, error)
+}
+
+// StaticTargets is a Discoverer with a fixed list of target URLs.
+type StaticTargets []string
+
+// Targets implements Discoverer.
+func (t StaticTargets) Targets() ([]string, error) {
+	return t, nil
+}
+
+// Balancer picks a target URL for each call of the client.
+type Balancer interface {
+	// Pick returns the target URL for the next call.
+	Pick() (target string, err error)
+	// Done reports the end of the call to the target picked before.
+	Done(target string, failed bool)
+}
+
+// ClientBalancer makes the client send calls to targets picked by the
+// balancer instead of the URL given to NewClient. Retries pick a target anew.
+func ClientBalancer(balancer Balancer) ClientOption {
+	return func(c *Client) { c.balancer = balancer }
+}
+
+// NewRoundRobin constructs a balancer which picks targets in turn.
+// Failed targets are evicted for evictFor, zero disables eviction.
+func NewRoundRobin(discoverer Discoverer, evictFor time.Duration) Balancer {
+	return newBalancer(discoverer, evictFor, false)
+}
+
+// NewLeastInFlight constructs a balancer which picks the target with the least
+// number of calls in flight. Failed targets are evicted for evictFor,
+// zero disables eviction.
+func NewLeastInFlight(discoverer Discoverer, evictFor time.Duration) Balancer {
+	return newBalancer(discoverer, evictFor, true)
+}
+
+type targetState struct {
+	inFlight     int
+	evictedUntil time.Time
+}
+
+type balancer struct {
+	discoverer  Discoverer
+	evictFor    time.Duration
+	leastLoaded bool
+
+	next    uint64
+	lock    sync.Mutex
+	targets map[string]*targetState
+}
+
+func newBalancer(discoverer Discoverer, evictFor time.Duration, leastLoaded bool) *balancer {
+	return &balancer{
+		discoverer:  discoverer,
+		evictFor:    evictFor,
+		leastLoaded: leastLoaded,
+		targets:     make(map[string]*targetState),
+	}
+}
+
+// Pick implements Balancer. When all targets are evicted they are used anyway,
+// since a possibly failing call is better than no call at all.
+func (b *balancer) Pick() (target string, err error) {
+
+	var targets []string
+
+	if targets, err = b.discoverer.Targets(); err != nil {
+		return
+	}
+
+	if len(targets) == 0 {
+		return "", ErrNoTargets
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	available := make([]string, 0, len(targets))
+
+	for _, t := range targets {
+		if b.state(t).evictedUntil.Before(now) {
+			available = append(available, t)
+		}
+	}
+
+	if len(available) == 0 {
+		available = targets
+	}
+
+	b.next++
+	offset := int(b.next % uint64(len(available)))
+	target = available[offset]
+
+	if b.leastLoaded {
+		for i := range available {
+			candidate := available[(offset+i)%len(available)]
+			if b.state(candidate).inFlight < b.state(target).inFlight {
+				target = candidate
+			}
+		}
+	}
+
+	b.state(target).inFlight++
+	return
+}
+
+// Done implements Balancer.
+func (b *balancer) Done(target string, failed bool) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state := b.state(target)
+
+	if state.inFlight > 0 {
+		state.inFlight--
+	}
+
+	if failed && b.evictFor > 0 {
+		state.evictedUntil = time.Now().Add(b.evictFor)
+	}
+}
+
+// state returns the state of the target, b.lock must be held.
+func (b *balancer) state(target string) *targetState {
+
+	state, found := b.targets[target]
+
+	if !found {
+		state = new(targetState)
+		b.targets[target] = state
+	}
+	return state
+}
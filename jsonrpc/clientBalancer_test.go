@@ -0,0 +1,120 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyTargets is a Discoverer failing while fail is set.
+type flakyTargets struct {
+	targets []string
+	fail    int32
+}
+
+func (d *flakyTargets) Targets() ([]string, error) {
+
+	if atomic.LoadInt32(&d.fail) != 0 {
+		return nil, errors.New("discovery failed")
+	}
+	return d.targets, nil
+}
+
+func TestRoundRobin(t *testing.T) {
+
+	balancer := NewRoundRobin(StaticTargets{"a", "b", "c"}, time.Minute)
+	picks := make(map[string]int)
+
+	for i := 0; i < 6; i++ {
+		target, err := balancer.Pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		picks[target]++
+		balancer.Done(target, target == "c")
+	}
+
+	if picks["a"] != 2 || picks["b"] != 3 || picks["c"] != 1 {
+		t.Errorf("picks %v, want c evicted after its failure", picks)
+	}
+}
+
+func TestLeastInFlight(t *testing.T) {
+
+	balancer := NewLeastInFlight(StaticTargets{"a", "b"}, 0)
+
+	first, _ := balancer.Pick()
+
+	for i := 0; i < 3; i++ {
+		target, _ := balancer.Pick()
+		if target == first {
+			t.Fatalf("pick %d: busy target %s is picked", i, target)
+		}
+		balancer.Done(target, false)
+	}
+}
+
+func TestBalancerNoTargets(t *testing.T) {
+
+	if _, err := NewRoundRobin(StaticTargets{}, 0).Pick(); err != ErrNoTargets {
+		t.Errorf("error %v, want ErrNoTargets", err)
+	}
+}
+
+func TestClientBalancer(t *testing.T) {
+
+	first, firstSrv := newCountingServer(t)
+	defer firstSrv.Close()
+
+	second, secondSrv := newCountingServer(t)
+	defer secondSrv.Close()
+
+	atomic.StoreInt32(&second.status, http.StatusBadGateway)
+
+	discoverer := &flakyTargets{targets: []string{firstSrv.URL, secondSrv.URL}}
+	client := NewClient("", "echo",
+		ClientBalancer(NewRoundRobin(discoverer, time.Minute)),
+		ClientRetry(2, time.Millisecond, time.Millisecond),
+		ClientCircuitBreaker(2, 20*time.Millisecond),
+	)
+	call := func() error {
+		_, err := client.Endpoint()(context.Background(), testParams{A: 1})
+		return err
+	}
+
+	// the failed target is evicted, the retry goes to the healthy one
+	for i := 0; i < 4; i++ {
+		if err := call(); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+
+	if second.count() != 1 || first.count() != 4 {
+		t.Errorf("calls %d and %d, want 4 and 1", first.count(), second.count())
+	}
+
+	// a failed discovery of the half-open breaker doesn't keep it open
+	atomic.StoreInt32(&first.status, http.StatusBadGateway)
+
+	if err := call(); err == nil {
+		t.Fatal("call to failing targets succeeded")
+	}
+
+	atomic.StoreInt32(&first.status, 0)
+	atomic.StoreInt32(&second.status, 0)
+	atomic.StoreInt32(&discoverer.fail, 1)
+	time.Sleep(30 * time.Millisecond)
+
+	if err := call(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error %v, want the discovery error", err)
+	}
+
+	atomic.StoreInt32(&discoverer.fail, 0)
+
+	if err := call(); err != nil {
+		t.Errorf("breaker stays open after the failed discovery: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errNotSent = errors.New("batch is not sent")
+
+// Batch accumulates calls to several methods and sends them to the server
+// as a single JSON RPC batch. Params are encoded and results decoded by the
+// codecs of the client the batch is created from. Batch is not safe for
+// concurrent use.
+type Batch struct {
+	client   *Client
+	calls    []*BatchCall
+	requests []Request
+	err      error
+}
+
+// BatchCall is a single call of the batch. Its result is available after
+// the batch is sent.
+type BatchCall struct {
+	id     *RequestID
+	method string
+	result interface{}
+	raw    json.RawMessage
+	rpcErr json.RawMessage
+	codec  Codec
+	err    error
+}
+
+// Batch creates an empty batch sent to the target URL of the client.
+func (c *Client) Batch() *Batch {
+	return &Batch{client: c}
+}
+
+// Call adds a call of the method to the batch.
+func (b *Batch) Call(ctx context.Context, method string, request interface{}) (call *BatchCall) {
+
+	call = &BatchCall{
+		method: method,
+		err:    errNotSent,
+		id:     b.client.requestID.Generate(),
+	}
+
+	b.calls = append(b.calls, call)
+	b.add(ctx, method, request, call.id)
+	return
+}
+
+// Notify adds a notification of the method to the batch.
+func (b *Batch) Notify(ctx context.Context, method string, request interface{}) {
+	b.add(ctx, method, request, nil)
+}
+
+func (b *Batch) add(ctx context.Context, method string, request interface{}, id *RequestID) {
+
+	params, err := b.client.enc(context.WithValue(ctx, codecKey, b.client.codec), request)
+
+	if err != nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("encode params of %s: %s", method, err)
+		}
+		return
+	}
+
+	b.requests = append(b.requests, Request{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  params,
+		ID:      id,
+	})
+}
+
+// Len returns the number of calls and notifications in the batch.
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+// Send sends the batch and correlates responses with calls by RequestID.
+// The returned error concerns the batch as a whole, errors of particular
+// calls are returned by their Result.
+func (b *Batch) Send(ctx context.Context) (err error) {
+
+	if b.err != nil {
+		return b.err
+	}
+
+	if len(b.requests) == 0 {
+		return nil
+	}
+
+	if metrics := b.client.metrics; metrics != nil {
+		defer func(started time.Time) { b.observe(metrics, err, time.Since(started)) }(time.Now())
+	}
+
+	ctx = context.WithValue(ctx, codecKey, b.client.codec)
+
+	var body []byte
+	if ctx, body, err = b.client.call(ctx, "", b.requests); err != nil {
+		return
+	}
+
+	if len(b.calls) == 0 {
+		return nil
+	}
+
+	codec := b.client.codec
+
+	// binary responses are handled as equal JSON ones
+	if binary, ok := codec.(BinaryCodec); ok {
+		var raw json.RawMessage
+		if err = binary.Unmarshal(body, &raw); err != nil {
+			return
+		}
+		body, codec = raw, binary.JSON()
+	}
+
+	var respList []ResponseRaw
+
+	switch firstByte(body) {
+
+	case '[':
+		if err = codec.Unmarshal(body, &respList); err != nil {
+			return
+		}
+
+	case '{':
+		var resp ResponseRaw
+		if err = codec.Unmarshal(body, &resp); err != nil {
+			return
+		}
+		// the server rejected the batch as a whole
+		if resp.ID == nil && resp.Error != nil {
+			_, err = b.client.decodeResponse(ctx, resp, b.client.dec)
+			return
+		}
+		respList = append(respList, resp)
+
+	default:
+		return fmt.Errorf("unexpected batch response: %q", body)
+	}
+
+	calls := make(map[string]*BatchCall, len(b.calls))
+
+	for _, call := range b.calls {
+		call.err = fmt.Errorf("no response for %s call", call.method)
+		calls[call.id.key()] = call
+	}
+
+	for _, resp := range respList {
+
+		call, found := calls[resp.ID.key()]
+
+		if !found {
+			continue
+		}
+		call.raw = resp.Result
+		call.rpcErr = resp.Error
+		call.codec = codec
+		call.result, call.err = b.client.decodeResponse(ctx, resp, b.client.dec)
+	}
+	return
+}
+
+// observe records the batch and its calls with the latency of the batch.
+// Notifications fail only with the batch as a whole.
+func (b *Batch) observe(metrics *Metrics, err error, duration time.Duration) {
+
+	methods := make([]string, 0, len(b.requests))
+
+	for _, req := range b.requests {
+		if methods = append(methods, req.Method); req.ID == nil {
+			metrics.observeCall(clientSide, req.Method, callCode(nil, err), duration)
+		}
+	}
+	metrics.observeBatch(clientSide, methods)
+
+	for _, call := range b.calls {
+		callErr := call.err
+		if err != nil {
+			callErr = err
+		}
+		metrics.observeCall(clientSide, call.method, callCode(call.rpcErr, callErr), duration)
+	}
+}
+
+// Result returns the result of the call decoded by the client decoder.
+func (call *BatchCall) Result() (interface{}, error) {
+	return call.result, call.err
+}
+
+// Decode unmarshals the raw result of the call into v with the codec of the client.
+func (call *BatchCall) Decode(v interface{}) error {
+
+	if call.err != nil {
+		return call.err
+	}
+	return call.codec.Unmarshal(call.raw, v)
+}
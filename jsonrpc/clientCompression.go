@@ -0,0 +1,62 @@
+package jsonrpc
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+// ClientCompression compresses request bodies of at least threshold bytes
+// with the content coding, which is one of utils.EncodingBrotli,
+// utils.EncodingGzip or utils.EncodingDeflate, and asks the server for
+// compressed responses. Compressed responses are decompressed regardless
+// of the option.
+func ClientCompression(encoding string, threshold int) ClientOption {
+	return func(c *Client) {
+		c.compression = encoding
+		c.compressionThreshold = threshold
+	}
+}
+
+// compressRequest sets the body of the request, compressed if needed.
+func (c *Client) compressRequest(req *fasthttp.Request, data []byte) (err error) {
+
+	if c.compression != "" {
+
+		req.Header.Set("Accept-Encoding", utils.AcceptEncoding)
+
+		if len(data) >= c.compressionThreshold {
+			if data, err = utils.Compress(c.compression, data); err != nil {
+				return
+			}
+			req.Header.Set("Content-Encoding", c.compression)
+		}
+	}
+
+	req.SetBody(data)
+	return
+}
+
+// decompressResponse replaces the compressed body of the response with
+// the decompressed one, which is limited by MaxResponseBodySize of the
+// fasthttp client as compressed bodies are.
+func (c *Client) decompressResponse(resp *fasthttp.Response) error {
+
+	encoding := strings.ToLower(strings.TrimSpace(string(resp.Header.Peek("Content-Encoding"))))
+
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	body, err := utils.Decompress(encoding, resp.Body(), int64(c.client.MaxResponseBodySize))
+
+	if err != nil {
+		return err
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.SetBody(body)
+	return nil
+}
@@ -0,0 +1,207 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrCircuitOpen is returned by the client while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ClientTimeout limits the time of a single call, retries included.
+// The deadline of the call context is honored if it comes earlier.
+func ClientTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// ClientRetry retries calls failed with transport errors, HTTP error statuses
+// of responses without a JSON RPC error, e.g. error pages of proxies, or JSON
+// RPC errors with one of the codes up to attempts times. Delay between
+// attempts grows exponentially from minBackoff up to maxBackoff.
+func ClientRetry(attempts int, minBackoff, maxBackoff time.Duration, codes ...int) ClientOption {
+	return func(c *Client) {
+		c.retry = &retryPolicy{
+			attempts:   attempts,
+			minBackoff: minBackoff,
+			maxBackoff: maxBackoff,
+			codes:      make(map[int]bool),
+		}
+		for _, code := range codes {
+			c.retry.codes[code] = true
+		}
+	}
+}
+
+// ClientCircuitBreaker stops calls for cooldown after threshold consecutive
+// failures, returning ErrCircuitOpen. After cooldown a single trial call is
+// allowed, which closes the breaker on success. Failures are classified the
+// same way as for retries. Threshold below 1 is treated as 1.
+func ClientCircuitBreaker(threshold int, cooldown time.Duration) ClientOption {
+	return func(c *Client) {
+		if threshold < 1 {
+			threshold = 1
+		}
+		c.breaker = &circuitBreaker{
+			threshold: threshold,
+			cooldown:  cooldown,
+		}
+	}
+}
+
+type retryPolicy struct {
+	attempts   int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	codes      map[int]bool
+}
+
+// retryable checks whether response body contains an error with retryable code.
+func (p *retryPolicy) retryable(codec Codec, body []byte) bool {
+
+	if p == nil || len(p.codes) == 0 {
+		return false
+	}
+
+	code, found := responseErrorCode(codec, body)
+	return found && p.codes[code]
+}
+
+// statusError returns the error of the response with non-2xx status, unless
+// it carries a JSON RPC error, e.g. with ServerErrorStatus set on the server.
+// Such responses are decoded as usual, other ones, e.g. error pages of
+// proxies, fail the call.
+func statusError(codec Codec, resp *fasthttp.Response) error {
+
+	status := resp.StatusCode()
+
+	if status >= 200 && status < 300 {
+		return nil
+	}
+
+	if _, found := responseErrorCode(codec, resp.Body()); found {
+		return nil
+	}
+	return fmt.Errorf("unexpected HTTP status %d", status)
+}
+
+// responseErrorCode returns the code of the error in the single response body.
+func responseErrorCode(codec Codec, body []byte) (code int, found bool) {
+
+	var resp struct {
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+
+	if err := codec.Unmarshal(body, &resp); err != nil || resp.Error == nil {
+		return
+	}
+	return resp.Error.Code, true
+}
+
+// wait sleeps before the next attempt. It returns false if no attempts left,
+// or the context is done, or the deadline comes before the next attempt.
+func (p *retryPolicy) wait(ctx context.Context, attempt int, deadline time.Time) bool {
+
+	if p == nil || attempt+1 >= p.attempts {
+		return false
+	}
+
+	backoff := p.minBackoff << uint(attempt)
+
+	if backoff <= 0 || backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+
+	// jitter prevents retries of many clients at the same moment
+	if backoff > 0 {
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+
+	if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+		return false
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type circuitBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+// allow returns ErrCircuitOpen if the call must not be done.
+func (b *circuitBreaker) allow() error {
+
+	if b == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+
+	b.trial = true
+	return nil
+}
+
+// cancel releases the allowed call, which was not done, so it doesn't take
+// the trial call of the half-open breaker.
+func (b *circuitBreaker) cancel() {
+
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+}
+
+// done records the result of the allowed call.
+func (b *circuitBreaker) done(success bool) {
+
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+
+	if success {
+		b.failures = 0
+		return
+	}
+
+	if b.failures++; b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,145 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler counts requests and serves them by h, or fails them with
+// the status if it's set.
+type countingHandler struct {
+	h      http.Handler
+	calls  int32
+	status int32
+}
+
+func (c *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	atomic.AddInt32(&c.calls, 1)
+
+	if status := atomic.LoadInt32(&c.status); status != 0 {
+		http.Error(w, "proxy error", int(status))
+		return
+	}
+	c.h.ServeHTTP(w, r)
+}
+
+func (c *countingHandler) count() int32 {
+	return atomic.LoadInt32(&c.calls)
+}
+
+func newCountingServer(t *testing.T, options ...ServerOption) (*countingHandler, *httptest.Server) {
+
+	s, _ := newTestServer(t, options...)
+	handler := &countingHandler{h: s}
+	return handler, httptest.NewServer(handler)
+}
+
+func TestClientRetry(t *testing.T) {
+
+	handler, srv := newCountingServer(t, ServerErrorStatus(ErrorStatus))
+	defer srv.Close()
+
+	retry := ClientRetry(3, time.Millisecond, 5*time.Millisecond, MethodNotFoundError)
+
+	// the endpoint error is sent with HTTP 500, but it's not retryable
+	_, err := NewClient(srv.URL, "fail", retry).Endpoint()(context.Background(), testParams{})
+
+	var rpcErr Error
+
+	if !errors.As(err, &rpcErr) || rpcErr.Code != InternalError || handler.count() != 1 {
+		t.Errorf("error %v after %d calls, want the endpoint error after 1 call", err, handler.count())
+	}
+
+	// retryable codes are retried
+	atomic.StoreInt32(&handler.calls, 0)
+
+	if _, err = NewClient(srv.URL, "missing", retry).Endpoint()(context.Background(), testParams{}); err == nil || handler.count() != 3 {
+		t.Errorf("error %v after %d calls, want 3 calls", err, handler.count())
+	}
+
+	// error pages without JSON RPC errors are retried
+	atomic.StoreInt32(&handler.calls, 0)
+	atomic.StoreInt32(&handler.status, http.StatusBadGateway)
+
+	if _, err = NewClient(srv.URL, "echo", retry).Endpoint()(context.Background(), testParams{}); err == nil || handler.count() != 3 {
+		t.Errorf("error %v after %d calls, want 3 calls", err, handler.count())
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+
+	handler, srv := newCountingServer(t, ServerErrorStatus(ErrorStatus))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "echo", ClientCircuitBreaker(2, 50*time.Millisecond))
+	failing := NewClient(srv.URL, "fail", ClientCircuitBreaker(2, 50*time.Millisecond))
+	call := func(c *Client) error {
+		_, err := c.Endpoint()(context.Background(), testParams{A: 1})
+		return err
+	}
+
+	// endpoint errors don't open the breaker
+	for i := 0; i < 3; i++ {
+		if err := call(failing); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("breaker is opened by endpoint errors")
+		}
+	}
+
+	atomic.StoreInt32(&handler.status, http.StatusServiceUnavailable)
+
+	for i := 0; i < 2; i++ {
+		if err := call(client); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: error %v, want the status error", i, err)
+		}
+	}
+
+	atomic.StoreInt32(&handler.calls, 0)
+
+	if err := call(client); !errors.Is(err, ErrCircuitOpen) || handler.count() != 0 {
+		t.Fatalf("error %v after %d calls, want open breaker", err, handler.count())
+	}
+
+	// the trial call after cooldown closes the breaker
+	atomic.StoreInt32(&handler.status, 0)
+	time.Sleep(60 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := call(client); err != nil {
+			t.Fatalf("call %d after cooldown: %v", i, err)
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+
+	handler, srv := newCountingServer(t)
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "echo", ClientTimeout(50*time.Millisecond), ClientRetry(5, 20*time.Millisecond, 20*time.Millisecond))
+
+	started := time.Now()
+
+	if _, err := client.Endpoint()(context.Background(), testParams{A: 1, Delay: 200}); err == nil {
+		t.Error("call exceeding the timeout succeeded")
+	}
+
+	if elapsed := time.Since(started); elapsed > 150*time.Millisecond {
+		t.Errorf("call took %s, want the timeout of 50ms", elapsed)
+	}
+
+	// the context deadline comes before the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	atomic.StoreInt32(&handler.calls, 0)
+
+	if _, err := client.Endpoint()(ctx, testParams{A: 1, Delay: 200}); err == nil || handler.count() > 1 {
+		t.Errorf("error %v after %d calls, want deadline error after 1 call", err, handler.count())
+	}
+}
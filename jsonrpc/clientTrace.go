@@ -0,0 +1,146 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+// ClientPropagateHeaders restricts headers copied from the call context
+// (see utils.AddHeaderToContext) to the given keys. By default all context
+// headers are copied to outgoing requests, except entity, hop-by-hop,
+// cookie, forwarding and conditional headers, which are never copied.
+func ClientPropagateHeaders(allow ...string) ClientOption {
+	return func(c *Client) {
+		if c.allowHeaders == nil {
+			c.allowHeaders = make(map[string]bool)
+		}
+		for _, key := range allow {
+			c.allowHeaders[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// ClientSkipHeaders prevents headers with the given keys from being copied
+// from the call context to outgoing requests.
+func ClientSkipHeaders(deny ...string) ClientOption {
+	return func(c *Client) {
+		if c.denyHeaders == nil {
+			c.denyHeaders = make(map[string]bool)
+		}
+		for _, key := range deny {
+			c.denyHeaders[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// ClientDisablePropagation disables copying of context headers and
+// injection of the client span into outgoing requests.
+func ClientDisablePropagation() ClientOption {
+	return func(c *Client) { c.noPropagation = true }
+}
+
+// propagate copies context headers to the request and starts the client span
+// of the call, which is injected into the request headers.
+func (c *Client) propagate(ctx context.Context, path string, payload interface{}, req *fasthttp.Request) (context.Context, opentracing.Span) {
+
+	operation := strings.TrimPrefix(path, "/")
+
+	if operation == "" {
+		operation = "batch"
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, operation)
+
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPUrl.Set(span, string(req.RequestURI()))
+	span.SetTag("jsonrpc.method", operation)
+
+	if rpcReq, ok := payload.(Request); ok && rpcReq.ID != nil {
+		span.SetTag("jsonrpc.id", strings.Trim(rpcReq.ID.key(), `"`))
+	}
+
+	if c.noPropagation {
+		return ctx, span
+	}
+
+	for key, value := range utils.HeadersFromContext(ctx) {
+
+		key = strings.ToLower(key)
+
+		if localHeader(key) || c.denyHeaders[key] || (c.allowHeaders != nil && !c.allowHeaders[key]) {
+			continue
+		}
+		req.Header.Set(key, fmt.Sprint(value))
+	}
+
+	_ = opentracing.GlobalTracer().Inject(span.Context(), opentracing.HTTPHeaders, requestCarrier{req})
+	return ctx, span
+}
+
+// localHeaders are hop-by-hop headers, headers describing the body of the
+// request, which are set by the client itself, and headers of the incoming
+// request, which don't apply to calls made on its behalf: cookies, origin,
+// forwarding and conditional headers.
+var localHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"host":                true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+	"proxy-authorization": true,
+	"proxy-connection":    true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"origin":              true,
+	"referer":             true,
+	"range":               true,
+	"expect":              true,
+	"forwarded":           true,
+	"via":                 true,
+	"x-real-ip":           true,
+}
+
+// localHeader reports whether the header of the key must not be propagated.
+func localHeader(key string) bool {
+
+	for _, prefix := range []string{"content-", "accept", "if-", "x-forwarded-"} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return localHeaders[key]
+}
+
+// finishSpan records the outcome of the call and finishes its span.
+func finishSpan(span opentracing.Span, codec Codec, err error, resp *fasthttp.Response) {
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.SetTag("error.message", err.Error())
+	} else {
+		ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode()))
+		if code, found := responseErrorCode(codec, resp.Body()); found {
+			ext.Error.Set(span, true)
+			span.SetTag("jsonrpc.error_code", code)
+		}
+	}
+	span.Finish()
+}
+
+// requestCarrier satisfies opentracing.TextMapWriter for fasthttp requests.
+type requestCarrier struct {
+	req *fasthttp.Request
+}
+
+func (c requestCarrier) Set(key, value string) {
+	c.req.Header.Set(key, value)
+}
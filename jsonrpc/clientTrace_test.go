@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+func TestClientPropagateHeaders(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	var received http.Header
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		s.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	incoming := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	for key, value := range map[string]string{
+		"X-User-Id":         "user",
+		"X-Session-Id":      "session",
+		"Cookie":            "session=secret",
+		"Origin":            "https://example.com",
+		"Referer":           "https://example.com/page",
+		"X-Forwarded-For":   "10.0.0.1",
+		"X-Forwarded-Proto": "https",
+		"Forwarded":         "for=10.0.0.1",
+		"If-None-Match":     `"etag"`,
+		"Range":             "bytes=0-10",
+	} {
+		incoming.Header.Set(key, value)
+	}
+
+	ctx := utils.HttpToContext(context.Background(), incoming)
+
+	if _, err := NewClient(srv.URL, "echo").Endpoint()(ctx, testParams{A: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"X-User-Id", "X-Session-Id"} {
+		if received.Get(key) == "" {
+			t.Errorf("header %s is not propagated", key)
+		}
+	}
+
+	for _, key := range []string{"Cookie", "Origin", "Referer", "X-Forwarded-For", "X-Forwarded-Proto", "Forwarded", "If-None-Match", "Range"} {
+		if value := received.Get(key); value != "" {
+			t.Errorf("header %s: %s is propagated", key, value)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// NotificationHandler handles notifications pushed by the server.
+type NotificationHandler func(ctx context.Context, params json.RawMessage)
+
+// WSClient is a client WebSocket connection shared by clients created with
+// the ClientWebSocket option. Calls over the connection are concurrent,
+// responses are correlated with calls by RequestID.
+type WSClient struct {
+	conn *websocket.Conn
+
+	writeLock sync.Mutex
+
+	lock     sync.Mutex
+	err      error
+	codec    Codec
+	pending  map[string]*wsCall
+	handlers map[string]NotificationHandler
+
+	// notifications are dispatched in order by a single goroutine,
+	// queued is signalled once notifications are added
+	notifications []wsNotification
+	queued        chan struct{}
+	closed        chan struct{}
+}
+
+// wsCall waits for the response of the call or the batch, which is
+// registered in pending by ids of all its calls.
+type wsCall struct {
+	keys []string
+	wait chan []byte
+}
+
+type wsNotification struct {
+	handler NotificationHandler
+	params  json.RawMessage
+}
+
+// DialWS connects to the WebSocket JSON RPC server at url.
+func DialWS(ctx context.Context, url string, header http.Header) (c *WSClient, err error) {
+
+	var conn *websocket.Conn
+
+	if conn, _, err = websocket.DefaultDialer.DialContext(ctx, url, header); err != nil {
+		return
+	}
+
+	c = &WSClient{
+		conn:     conn,
+		codec:    DefaultCodec,
+		pending:  make(map[string]*wsCall),
+		handlers: make(map[string]NotificationHandler),
+		queued:   make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+
+	go c.readLoop()
+	go c.dispatchLoop()
+	return
+}
+
+// ClientWebSocket makes the client send calls over the WebSocket connection
+// instead of HTTP. Before and after functions, retries, the circuit breaker
+// and the balancer are not used in this mode. Calls are encoded by the codec
+// of the client, binary codecs are sent as binary frames, so clients sharing
+// the connection must use the same codec as the server.
+func ClientWebSocket(ws *WSClient) ClientOption {
+	return func(c *Client) { c.ws = ws }
+}
+
+// OnNotification registers the handler of notifications of the method.
+func (c *WSClient) OnNotification(method string, handler NotificationHandler) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.handlers[method] = handler
+}
+
+// Close closes the connection, pending calls fail with ErrConnClosed.
+func (c *WSClient) Close() error {
+	return c.conn.Close()
+}
+
+// call sends the payload encoded by the codec and waits for the response
+// until the deadline. Notifications return immediately with no response body.
+func (c *WSClient) call(ctx context.Context, deadline time.Time, codec Codec, payload interface{}) (body []byte, err error) {
+
+	var data []byte
+
+	if data, err = codec.Marshal(payload); err != nil {
+		return
+	}
+
+	messageType := websocket.TextMessage
+
+	if _, binary := codec.(BinaryCodec); binary {
+		messageType = websocket.BinaryMessage
+	}
+
+	c.lock.Lock()
+	c.codec = codec
+	c.lock.Unlock()
+
+	var wait chan []byte
+
+	if keys := payloadKeys(payload); len(keys) > 0 {
+
+		call := &wsCall{keys: keys, wait: make(chan []byte, 1)}
+		wait = call.wait
+
+		c.lock.Lock()
+		if c.err != nil {
+			c.lock.Unlock()
+			return nil, c.err
+		}
+		for _, key := range keys {
+			c.pending[key] = call
+		}
+		c.lock.Unlock()
+
+		defer func() {
+			c.lock.Lock()
+			c.release(call)
+			c.lock.Unlock()
+		}()
+	}
+
+	c.writeLock.Lock()
+	if !deadline.IsZero() {
+		_ = c.conn.SetWriteDeadline(deadline)
+	}
+	err = c.conn.WriteMessage(messageType, data)
+	c.writeLock.Unlock()
+
+	if err != nil || wait == nil {
+		return
+	}
+
+	var timeout <-chan time.Time
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case body = <-wait:
+		if body == nil {
+			return nil, ErrConnClosed
+		}
+		return body, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// readLoop dispatches responses to pending calls and notifications to handlers.
+func (c *WSClient) readLoop() {
+
+	defer func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.err = ErrConnClosed
+		for _, call := range c.pending {
+			c.release(call)
+			close(call.wait)
+		}
+		close(c.closed)
+	}()
+
+	for {
+
+		messageType, data, err := c.conn.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		// binary messages are transcoded to JSON to be correlated,
+		// calls receive them as is
+		doc := json.RawMessage(data)
+
+		if messageType == websocket.BinaryMessage {
+			c.lock.Lock()
+			binary, ok := c.codec.(BinaryCodec)
+			c.lock.Unlock()
+			if !ok || binary.Unmarshal(data, &doc) != nil {
+				continue
+			}
+		}
+
+		var messages []wsMessage
+
+		switch firstByte(doc) {
+
+		case '[':
+			if err = DefaultCodec.Unmarshal(doc, &messages); err != nil || len(messages) == 0 {
+				continue
+			}
+
+		case '{':
+			var message wsMessage
+			if err = DefaultCodec.Unmarshal(doc, &message); err != nil {
+				continue
+			}
+			messages = append(messages, message)
+
+		default:
+			continue
+		}
+
+		c.lock.Lock()
+		c.dispatch(data, messages)
+		c.lock.Unlock()
+	}
+}
+
+type wsMessage struct {
+	ID     *RequestID      `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// dispatch delivers the received data to the call waiting for it, the lock
+// must be held. Responses of a batch go to the batch as a whole. Errors
+// without id, e.g. of requests the server could not parse, can't be
+// correlated, so they are delivered to all pending calls.
+func (c *WSClient) dispatch(data []byte, messages []wsMessage) {
+
+	if len(messages) == 1 && messages[0].Method != "" {
+		if handler, found := c.handlers[messages[0].Method]; found {
+			c.notifications = append(c.notifications, wsNotification{handler: handler, params: messages[0].Params})
+			select {
+			case c.queued <- struct{}{}:
+			default:
+			}
+		}
+		return
+	}
+
+	if len(messages) == 1 && messages[0].ID == nil && messages[0].Error != nil {
+		for _, call := range c.pending {
+			c.release(call)
+			call.wait <- data
+		}
+		return
+	}
+
+	for _, message := range messages {
+		if message.ID == nil {
+			continue
+		}
+		if call, found := c.pending[message.ID.key()]; found {
+			c.release(call)
+			call.wait <- data
+			return
+		}
+	}
+	log.Debug("websocket response without call")
+}
+
+// release removes the call from pending calls, the lock must be held.
+func (c *WSClient) release(call *wsCall) {
+
+	for _, key := range call.keys {
+		if c.pending[key] == call {
+			delete(c.pending, key)
+		}
+	}
+}
+
+// dispatchLoop calls handlers of notifications in order of arrival until
+// the connection is closed.
+func (c *WSClient) dispatchLoop() {
+
+	for {
+
+		select {
+		case <-c.queued:
+		case <-c.closed:
+			return
+		}
+
+		c.lock.Lock()
+		notifications := c.notifications
+		c.notifications = nil
+		c.lock.Unlock()
+
+		for _, n := range notifications {
+			n.handler(context.Background(), n.params)
+		}
+	}
+}
+
+// payloadKeys returns keys to correlate the response with the payload,
+// which are ids of all calls of the batch, or nothing for notifications.
+func payloadKeys(payload interface{}) (keys []string) {
+
+	switch p := payload.(type) {
+
+	case Request:
+		if p.ID != nil {
+			keys = append(keys, p.ID.key())
+		}
+
+	case []Request:
+		for _, req := range p {
+			if req.ID != nil {
+				keys = append(keys, req.ID.key())
+			}
+		}
+	}
+	return
+}
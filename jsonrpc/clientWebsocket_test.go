@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWSClientCodecs(t *testing.T) {
+
+	for _, codec := range []Codec{JSONCodec{}, MessagePackCodec(), CBORCodec()} {
+
+		t.Run(codec.ContentType(), func(t *testing.T) {
+
+			s, _ := newTestServer(t, ServerCodecs(codec))
+			srv := httptest.NewServer(NewWSServer(s))
+			defer srv.Close()
+
+			ws, err := DialWS(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+
+			client := NewClient(srv.URL, "echo", ClientCodec(codec), ClientWebSocket(ws))
+
+			if result, err := client.Endpoint()(context.Background(), testParams{A: 3}); err != nil || result == nil {
+				t.Errorf("result %v, error %v", result, err)
+			}
+
+			if _, err = NewClient(srv.URL, "fail", ClientCodec(codec), ClientWebSocket(ws)).Endpoint()(context.Background(), testParams{}); err == nil {
+				t.Error("error of the call is lost")
+			}
+
+			batch := client.Batch()
+			first := batch.Call(context.Background(), "echo", testParams{A: 1})
+			second := batch.Call(context.Background(), "echo", testParams{A: 2})
+
+			if err = batch.Send(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var a, b int
+
+			if err = first.Decode(&a); err != nil || a != 1 {
+				t.Errorf("first call %d, error %v", a, err)
+			}
+
+			if err = second.Decode(&b); err != nil || b != 2 {
+				t.Errorf("second call %d, error %v", b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"strings"
+)
+
+const codecKey contextKey = "codec"
+
+// Codec encodes and decodes JSON RPC messages, e.g. with a faster JSON
+// implementation. Params, results and error data are kept as
+// json.RawMessage, so the codec must encode them as JSON values,
+// codecs of other encodings implement BinaryCodec.
+type Codec interface {
+	// ContentType is the media type of encoded messages, it is sent with
+	// messages and used to select the codec of requests.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StrictUnmarshaler is implemented by codecs able to reject object fields
+// unknown to the target type, see ServerDisallowUnknownFields. Params are
+// decoded by encoding/json in strict mode if the codec is not able to.
+type StrictUnmarshaler interface {
+	UnmarshalStrict(data []byte, v interface{}) error
+}
+
+// DefaultCodec is used by servers and clients created without codec options,
+// and by functions out of calls, e.g. EncodeParams. It must be set before
+// servers and clients are created.
+var DefaultCodec Codec = JSONCodec{}
+
+// JSONCodec is the codec of encoding/json.
+type JSONCodec struct{}
+
+// ContentType implements Codec.
+func (JSONCodec) ContentType() string {
+	return ContentType
+}
+
+// Marshal implements Codec.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// UnmarshalStrict implements StrictUnmarshaler.
+func (JSONCodec) UnmarshalStrict(data []byte, v interface{}) error {
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return errors.New("unexpected data after value")
+	}
+	return nil
+}
+
+// ServerCodecs sets codecs of the server. The codec of a request is selected
+// by its Content-Type, the codec of the response by the Accept header.
+// The first codec is used for requests of other content types and for
+// responses when no accepted codec is found. By default, DefaultCodec is used.
+func ServerCodecs(codecs ...Codec) ServerOption {
+	return func(s *Server) {
+		if len(codecs) > 0 {
+			s.codecs = codecs
+		}
+	}
+}
+
+// ClientCodec sets the codec of requests and responses of the client.
+// By default, DefaultCodec is used.
+func ClientCodec(codec Codec) ClientOption {
+	return func(c *Client) { c.codec = codec }
+}
+
+// CodecFromContext returns the codec of params and results of the call,
+// so custom endpoint codecs may decode params and encode results the same
+// way. DefaultCodec is returned out of calls.
+func CodecFromContext(ctx context.Context) Codec {
+
+	codec := messageCodec(ctx)
+
+	if binary, ok := codec.(BinaryCodec); ok {
+		return binary.JSON()
+	}
+	return codec
+}
+
+// messageCodec returns the codec of messages of the call.
+func messageCodec(ctx context.Context) Codec {
+
+	if codec, found := ctx.Value(codecKey).(Codec); found {
+		return codec
+	}
+	return DefaultCodec
+}
+
+// negotiate selects codecs of the request and the response by Content-Type
+// and Accept headers.
+func (s Server) negotiate(contentType, accept string) (request, response Codec) {
+
+	if request = s.codecs[0]; len(s.codecs) == 1 {
+		return request, request
+	}
+
+	if codec, found := s.codecOf(contentType); found {
+		request = codec
+	}
+
+	response = request
+
+	for _, accepted := range strings.Split(accept, ",") {
+
+		mediaType, params, err := mime.ParseMediaType(accepted)
+
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		if mediaType == "*/*" || mediaType == mediaTypeOf(response.ContentType()) {
+			break
+		}
+
+		if codec, found := s.codecOf(mediaType); found {
+			response = codec
+			break
+		}
+	}
+	return
+}
+
+// codecOf returns the codec of the content type, parameters are ignored.
+func (s Server) codecOf(contentType string) (codec Codec, found bool) {
+
+	mediaType := mediaTypeOf(contentType)
+
+	if mediaType == "" {
+		return nil, false
+	}
+
+	for _, codec = range s.codecs {
+		if mediaTypeOf(codec.ContentType()) == mediaType {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+func mediaTypeOf(contentType string) string {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
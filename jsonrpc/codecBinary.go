@@ -0,0 +1,217 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	// MessagePackContentType is the content type of MessagePack messages.
+	MessagePackContentType = "application/msgpack"
+
+	// CBORContentType is the content type of CBOR messages.
+	CBORContentType = "application/cbor"
+)
+
+// BinaryCodec is a codec of binary messages, e.g. MessagePack or CBOR.
+// Messages are equal to JSON RPC 2.0 ones in all but encoding: params,
+// results and error data are still JSON values, which are encoded by the
+// codec returned by JSON and transcoded along with the message.
+// Unmarshal must support *json.RawMessage, which receives the message
+// as JSON document.
+type BinaryCodec interface {
+	Codec
+	JSON() Codec
+}
+
+// MessagePackCodec returns the codec of MessagePack messages, JSON values
+// are encoded by DefaultCodec.
+func MessagePackCodec() BinaryCodec {
+
+	return transcoder{
+		contentType: MessagePackContentType,
+		json:        DefaultCodec,
+		marshal:     msgpack.Marshal,
+		unmarshal:   msgpack.Unmarshal,
+		scan:        scanMessagePack,
+	}
+}
+
+// CBORCodec returns the codec of CBOR messages, JSON values are encoded
+// by DefaultCodec.
+func CBORCodec() BinaryCodec {
+
+	return transcoder{
+		contentType: CBORContentType,
+		json:        DefaultCodec,
+		marshal:     cbor.Marshal,
+		unmarshal:   cbor.Unmarshal,
+		scan:        scanCBOR,
+	}
+}
+
+// transcoder is a binary codec encoding messages as equal JSON documents
+// transcoded to the binary format, so types of messages and values need
+// nothing but JSON support. Messages are scanned before they are decoded,
+// since binary decoders don't limit nesting.
+type transcoder struct {
+	contentType string
+	json        Codec
+	marshal     func(v interface{}) ([]byte, error)
+	unmarshal   func(data []byte, v interface{}) error
+	scan        func(s *binaryScanner) error
+}
+
+// ContentType implements Codec.
+func (t transcoder) ContentType() string {
+	return t.contentType
+}
+
+// JSON implements BinaryCodec.
+func (t transcoder) JSON() Codec {
+	return t.json
+}
+
+// Marshal implements Codec.
+func (t transcoder) Marshal(v interface{}) (data []byte, err error) {
+
+	if data, err = t.json.Marshal(v); err != nil {
+		return
+	}
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+
+	if value, err = fromJSON(value, 0); err != nil {
+		return
+	}
+	return t.marshal(value)
+}
+
+// Unmarshal implements Codec.
+func (t transcoder) Unmarshal(data []byte, v interface{}) (err error) {
+
+	if err = t.check(data, 0, 0); err != nil {
+		return
+	}
+
+	var value interface{}
+
+	if err = t.unmarshal(data, &value); err != nil {
+		return
+	}
+
+	if value, err = toJSON(value, 0); err != nil {
+		return
+	}
+
+	if data, err = json.Marshal(value); err != nil {
+		return
+	}
+
+	if raw, ok := v.(*json.RawMessage); ok {
+		*raw = data
+		return nil
+	}
+	return t.json.Unmarshal(data, v)
+}
+
+// check scans the message for nesting and string limits, zero maxDepth
+// is the default limit and zero maxString means no limit.
+func (t transcoder) check(data []byte, maxDepth, maxString int) error {
+
+	scanner := binaryScanner{
+		data:      data,
+		maxDepth:  maxDepth,
+		maxString: maxString,
+		item:      t.scan,
+	}
+	return scanner.scan()
+}
+
+// fromJSON converts numbers of the decoded JSON value to integers where
+// possible, so they are encoded as binary numbers.
+func fromJSON(value interface{}, depth int) (_ interface{}, err error) {
+
+	if depth > defaultMaxDepth {
+		return nil, limitError(fmt.Sprintf("nesting exceeds %d levels", defaultMaxDepth))
+	}
+
+	switch value := value.(type) {
+
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(value.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return value.Float64()
+
+	case []interface{}:
+		for i := range value {
+			if value[i], err = fromJSON(value[i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+	case map[string]interface{}:
+		for key := range value {
+			if value[key], err = fromJSON(value[key], depth+1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// toJSON converts maps of the decoded binary value to maps with string keys,
+// which are the only maps encodable as JSON objects.
+func toJSON(value interface{}, depth int) (_ interface{}, err error) {
+
+	if depth > defaultMaxDepth {
+		return nil, limitError(fmt.Sprintf("nesting exceeds %d levels", defaultMaxDepth))
+	}
+
+	switch value := value.(type) {
+
+	case []interface{}:
+		for i := range value {
+			if value[i], err = toJSON(value[i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+	case map[string]interface{}:
+		for key := range value {
+			if value[key], err = toJSON(value[key], depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("map key %v is not a string", key)
+			}
+			if object[name], err = toJSON(item, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	return value, nil
+}
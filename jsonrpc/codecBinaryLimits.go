@@ -0,0 +1,312 @@
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// limitError is the error of a binary message exceeding depth or string limits.
+type limitError string
+
+func (e limitError) Error() string {
+	return string(e)
+}
+
+// binaryScanner checks nesting and string lengths of a binary message
+// without decoding values, so decoders never recurse deeper than the limit.
+// Containers are tracked by an explicit stack to not recurse while scanning.
+type binaryScanner struct {
+	data      []byte
+	pos       int
+	maxDepth  int
+	maxString int
+	// stack holds the number of items left in open containers, -1 for
+	// containers of indefinite length, which end with a break code.
+	stack []int
+	// item scans the header of the next item and skips scalars.
+	item func(s *binaryScanner) error
+}
+
+func (s *binaryScanner) scan() (err error) {
+
+	if s.maxDepth <= 0 {
+		s.maxDepth = defaultMaxDepth
+	}
+
+	// the message is a container of a single item
+	s.stack = append(s.stack[:0], 1)
+
+	for len(s.stack) != 0 {
+
+		top := len(s.stack) - 1
+
+		if s.stack[top] == 0 {
+			s.stack = s.stack[:top]
+			continue
+		}
+
+		if s.pos >= len(s.data) {
+			return s.syntaxError("unexpected end of data")
+		}
+
+		if s.stack[top] > 0 {
+			s.stack[top]--
+		}
+
+		if err = s.item(s); err != nil {
+			return
+		}
+	}
+
+	if s.pos != len(s.data) {
+		return s.syntaxError("unexpected data after top-level value")
+	}
+	return
+}
+
+// open starts the container of items, which may be indefinite.
+func (s *binaryScanner) open(items uint64) error {
+
+	if len(s.stack) > s.maxDepth {
+		return limitError(fmt.Sprintf("nesting exceeds %d levels", s.maxDepth))
+	}
+
+	// each item takes a byte at least
+	if items != indefinite && items > uint64(len(s.data)-s.pos) {
+		return s.syntaxError("container length exceeds data")
+	}
+
+	if items == indefinite {
+		s.stack = append(s.stack, -1)
+	} else {
+		s.stack = append(s.stack, int(items))
+	}
+	return nil
+}
+
+// close ends the container of indefinite length.
+func (s *binaryScanner) close() error {
+
+	if top := len(s.stack) - 1; s.stack[top] == -1 {
+		s.stack = s.stack[:top]
+		return nil
+	}
+	return s.syntaxError("unexpected break code")
+}
+
+// skip skips the string or other data of size bytes.
+func (s *binaryScanner) skip(size uint64, isString bool) error {
+
+	if size > uint64(len(s.data)-s.pos) {
+		return s.syntaxError("unexpected end of data")
+	}
+
+	if isString && s.maxString > 0 && size > uint64(s.maxString) {
+		return limitError(fmt.Sprintf("string exceeds %d bytes", s.maxString))
+	}
+
+	s.pos += int(size)
+	return nil
+}
+
+// readUint reads the big endian unsigned integer of size bytes.
+func (s *binaryScanner) readUint(size int) (value uint64, err error) {
+
+	if size > len(s.data)-s.pos {
+		return 0, s.syntaxError("unexpected end of data")
+	}
+
+	switch size {
+	case 1:
+		value = uint64(s.data[s.pos])
+	case 2:
+		value = uint64(binary.BigEndian.Uint16(s.data[s.pos:]))
+	case 4:
+		value = uint64(binary.BigEndian.Uint32(s.data[s.pos:]))
+	case 8:
+		value = binary.BigEndian.Uint64(s.data[s.pos:])
+	}
+
+	s.pos += size
+	return
+}
+
+func (s *binaryScanner) syntaxError(message string) error {
+	return fmt.Errorf("%s at offset %d", message, s.pos)
+}
+
+// indefinite is the length of containers ending with a break code.
+const indefinite = ^uint64(0)
+
+// scanMessagePack scans the next item of the MessagePack message.
+func scanMessagePack(s *binaryScanner) (err error) {
+
+	code := s.data[s.pos]
+	s.pos++
+
+	switch {
+
+	case code <= 0x7f || code >= 0xe0 || code == 0xc0 || code == 0xc2 || code == 0xc3:
+		// fixint, nil and bool
+		return nil
+
+	case code <= 0x8f:
+		return s.open(2 * uint64(code&0x0f))
+
+	case code <= 0x9f:
+		return s.open(uint64(code & 0x0f))
+
+	case code <= 0xbf:
+		return s.skip(uint64(code&0x1f), true)
+	}
+
+	var size uint64
+
+	switch code {
+
+	case 0xc4, 0xc5, 0xc6:
+		// bin
+		if size, err = s.readUint(1 << (code - 0xc4)); err != nil {
+			return
+		}
+		return s.skip(size, true)
+
+	case 0xd9, 0xda, 0xdb:
+		// str
+		if size, err = s.readUint(1 << (code - 0xd9)); err != nil {
+			return
+		}
+		return s.skip(size, true)
+
+	case 0xc7, 0xc8, 0xc9:
+		// ext with the type byte
+		if size, err = s.readUint(1 << (code - 0xc7)); err != nil {
+			return
+		}
+		return s.skip(size+1, false)
+
+	case 0xca, 0xce, 0xd2:
+		return s.skip(4, false)
+
+	case 0xcb, 0xcf, 0xd3:
+		return s.skip(8, false)
+
+	case 0xcc, 0xd0:
+		return s.skip(1, false)
+
+	case 0xcd, 0xd1:
+		return s.skip(2, false)
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext with the type byte
+		return s.skip(1+1<<(code-0xd4), false)
+
+	case 0xdc, 0xdd:
+		// array
+		if size, err = s.readUint(2 << (code - 0xdc)); err != nil {
+			return
+		}
+		return s.open(size)
+
+	case 0xde, 0xdf:
+		// map
+		if size, err = s.readUint(2 << (code - 0xde)); err != nil {
+			return
+		}
+		return s.open(2 * size)
+	}
+	return s.syntaxError(fmt.Sprintf("invalid code 0x%02x", code))
+}
+
+// scanCBOR scans the next item of the CBOR message.
+func scanCBOR(s *binaryScanner) (err error) {
+
+	major, info := s.data[s.pos]>>5, s.data[s.pos]&0x1f
+	s.pos++
+
+	if major == 7 && info == 31 {
+		return s.close()
+	}
+
+	var arg uint64
+
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		if arg, err = s.readUint(1 << (info - 24)); err != nil {
+			return
+		}
+	case info == 31 && major >= 2 && major <= 5:
+		arg = indefinite
+	default:
+		return s.syntaxError(fmt.Sprintf("invalid additional information %d", info))
+	}
+
+	switch major {
+
+	case 2, 3:
+		if arg == indefinite {
+			return s.chunks(major)
+		}
+		return s.skip(arg, true)
+
+	case 4:
+		return s.open(arg)
+
+	case 5:
+		if arg != indefinite {
+			arg *= 2
+		}
+		return s.open(arg)
+
+	case 6:
+		// the tag content is a nested item
+		return s.open(1)
+	}
+	// integers and simple values
+	return nil
+}
+
+// chunks skips chunks of the indefinite length string, the string
+// limit applies to the total length.
+func (s *binaryScanner) chunks(major byte) (err error) {
+
+	var total uint64
+
+	for {
+
+		if s.pos >= len(s.data) {
+			return s.syntaxError("unexpected end of data")
+		}
+
+		if s.data[s.pos] == 0xff {
+			s.pos++
+			return nil
+		}
+
+		if s.data[s.pos]>>5 != major || s.data[s.pos]&0x1f > 27 {
+			return s.syntaxError("invalid chunk of indefinite length string")
+		}
+
+		info := s.data[s.pos] & 0x1f
+		s.pos++
+
+		size := uint64(info)
+
+		if info >= 24 {
+			if size, err = s.readUint(1 << (info - 24)); err != nil {
+				return
+			}
+		}
+
+		if size > uint64(len(s.data)-s.pos) {
+			return s.syntaxError("unexpected end of data")
+		}
+
+		if total += size; s.maxString > 0 && total > uint64(s.maxString) {
+			return limitError(fmt.Sprintf("string exceeds %d bytes", s.maxString))
+		}
+		s.pos += int(size)
+	}
+}
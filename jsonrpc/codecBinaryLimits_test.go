@@ -0,0 +1,131 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// nestedMessage returns the message of depth nested arrays.
+func nestedMessage(codec Codec, depth int) []byte {
+
+	// single item arrays of MessagePack and CBOR
+	open, item := byte(0x91), byte(0x01)
+
+	if codec.ContentType() == CBORContentType {
+		open = 0x81
+	}
+	return append(bytes.Repeat([]byte{open}, depth), item)
+}
+
+// serveBinary posts the binary body to the handler and returns the response as JSON.
+func serveBinary(t *testing.T, h http.Handler, codec Codec, body []byte) string {
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", codec.ContentType())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var raw json.RawMessage
+
+	if err := codec.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("response %q: %v", w.Body.Bytes(), err)
+	}
+	return string(raw)
+}
+
+func TestBinaryDepthLimit(t *testing.T) {
+
+	for _, codec := range []BinaryCodec{MessagePackCodec(), CBORCodec()} {
+
+		t.Run(codec.ContentType(), func(t *testing.T) {
+
+			limited, _ := newTestServer(t, ServerCodecs(JSONCodec{}, codec), ServerMaxDepth(32))
+			unlimited, _ := newTestServer(t, ServerCodecs(JSONCodec{}, codec))
+
+			// a batch of nested arrays within the limit is served
+			if body := serveBinary(t, limited, codec, nestedMessage(codec, 3)); strings.Contains(body, "nesting exceeds") {
+				t.Errorf("message within limit is rejected: %s", body)
+			}
+
+			tests := []struct {
+				name string
+				h    http.Handler
+				body []byte
+				code int
+			}{
+				{"exceeds limit", limited, nestedMessage(codec, 33), InvalidRequestError},
+				{"deep body", limited, nestedMessage(codec, 8<<20), InvalidRequestError},
+				{"deep body without limit", unlimited, nestedMessage(codec, 8<<20), ParseError},
+				{"truncated", limited, nestedMessage(codec, 3)[:3], ParseError},
+			}
+
+			for _, test := range tests {
+
+				t.Run(test.name, func(t *testing.T) {
+
+					if resp := decodeSingle(t, serveBinary(t, test.h, codec, test.body)); resp.Error == nil || resp.Error.Code != test.code {
+						t.Errorf("error %v, want code %d", resp.Error, test.code)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestBinaryStringLimit(t *testing.T) {
+
+	for _, codec := range []BinaryCodec{MessagePackCodec(), CBORCodec()} {
+
+		s, _ := newTestServer(t, ServerCodecs(JSONCodec{}, codec), ServerMaxStringLength(8))
+
+		for method, code := range map[string]int{"echo": 0, "echo_too_long": InvalidRequestError} {
+
+			body, err := codec.Marshal(map[string]interface{}{"jsonrpc": Version, "method": method, "params": map[string]int{"a": 1}, "id": 1})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp := decodeSingle(t, serveBinary(t, s, codec, body)); (code == 0) != (resp.Error == nil) || (resp.Error != nil && resp.Error.Code != code) {
+				t.Errorf("%s %s: error %v, want code %d", codec.ContentType(), method, resp.Error, code)
+			}
+		}
+	}
+}
+
+func TestBinaryScan(t *testing.T) {
+
+	value := map[string]interface{}{
+		"string":  strings.Repeat("s", 70000),
+		"short":   "s",
+		"ints":    []interface{}{0, -1, 127, 128, -33, 255, 256, 65536, -65536, 1 << 40, -(1 << 40), uint64(1 << 63)},
+		"floats":  []interface{}{0.5, -1.25e300},
+		"bools":   []interface{}{true, false, nil},
+		"nested":  map[string]interface{}{"array": make([]interface{}, 20), "object": map[string]interface{}{}},
+		"objects": []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"b": []interface{}{}}},
+	}
+
+	for _, codec := range []BinaryCodec{MessagePackCodec(), CBORCodec()} {
+
+		data, err := codec.Marshal(value)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded map[string]interface{}
+
+		if err = codec.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("%s: %v", codec.ContentType(), err)
+		}
+
+		if err = codec.(transcoder).check(data[:len(data)-1], 0, 0); err == nil {
+			t.Errorf("%s: truncated message is accepted", codec.ContentType())
+		}
+	}
+}
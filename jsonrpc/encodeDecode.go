@@ -9,11 +9,17 @@ import (
 
 // Server-Side Codec
 
-// EndpointCodec defines a server Endpoint and its associated codecs
+// EndpointCodec defines a server Endpoint and its associated codecs.
+// Params and Result are optional samples of request and response types,
+// which describe the method in the OpenRPC document together with Summary.
 type EndpointCodec struct {
 	Endpoint endpoint.Endpoint
 	Decode   DecodeRequestFunc
 	Encode   EncodeResponseFunc
+
+	Params  interface{}
+	Result  interface{}
+	Summary string
 }
 
 // EndpointCodecMap maps the Request.Method to the proper EndpointCodec
@@ -38,6 +38,16 @@ const (
 
 	// InternalError defines a server error
 	InternalError int = -32603
+
+	// UnauthorizedError defines the caller is not authenticated or its token is invalid.
+	UnauthorizedError int = -32001
+
+	// ForbiddenError defines the caller has no permission to call the method.
+	ForbiddenError int = -32003
+
+	// RateLimitError defines the call exceeds rate or concurrency limits of the method.
+	// Data of the error is RateLimitData.
+	RateLimitError int = -32029
 )
 
 var errorMessage = map[int]string{
@@ -46,6 +56,9 @@ var errorMessage = map[int]string{
 	MethodNotFoundError: "The method does not exist / is not available.",
 	InvalidParamsError:  "Invalid method parameter(s).",
 	InternalError:       "Internal JSON-RPC error.",
+	UnauthorizedError:   "Authentication required.",
+	ForbiddenError:      "Permission denied.",
+	RateLimitError:      "Too many requests.",
 }
 
 // ErrorMessage returns a message for the JSON RPC error code. It returns the empty
@@ -98,3 +111,21 @@ func (e internalError) Error() string {
 func (e internalError) ErrorCode() int {
 	return InternalError
 }
+
+type unauthorizedError string
+
+func (e unauthorizedError) Error() string {
+	return string(e)
+}
+func (e unauthorizedError) ErrorCode() int {
+	return UnauthorizedError
+}
+
+type forbiddenError string
+
+func (e forbiddenError) Error() string {
+	return string(e)
+}
+func (e forbiddenError) ErrorCode() int {
+	return ForbiddenError
+}
@@ -0,0 +1,129 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// ErrorDataer is checked by the server. If an error value implements
+// ErrorDataer, the result of ErrorData() will be used as the data field
+// of the JSON RPC error.
+type ErrorDataer interface {
+	ErrorData() interface{}
+}
+
+// ErrorMapper converts an endpoint error to a JSON RPC error.
+// It returns nil if the error is not handled by the mapper.
+type ErrorMapper func(ctx context.Context, err error) *Error
+
+// MapErrorIs maps errors matching target with errors.Is to the code.
+func MapErrorIs(target error, code int) ErrorMapper {
+	return func(_ context.Context, err error) *Error {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return codedError(err, code)
+	}
+}
+
+// MapErrorAs maps errors matching the type of target with errors.As to the
+// code. Target is a value of the error type, e.g.
+// MapErrorAs((*os.PathError)(nil), NotFound), or a pointer to the interface
+// type errors must implement, e.g. MapErrorAs((*ErrorCoder)(nil), code).
+func MapErrorAs(target interface{}, code int) ErrorMapper {
+
+	targetType := reflect.TypeOf(target)
+
+	switch {
+
+	case targetType == nil:
+		panic("jsonrpc: MapErrorAs target must be typed")
+
+	case targetType.Implements(errorType):
+
+	case targetType.Kind() == reflect.Ptr && targetType.Elem().Kind() == reflect.Interface:
+		targetType = targetType.Elem()
+
+	default:
+		panic("jsonrpc: MapErrorAs target must implement error or point to an interface")
+	}
+
+	return func(_ context.Context, err error) *Error {
+
+		found := reflect.New(targetType)
+
+		if !errors.As(err, found.Interface()) {
+			return nil
+		}
+
+		// values of the chain are errors, even if matched by an interface
+		return codedError(found.Elem().Interface().(error), code)
+	}
+}
+
+// ServerErrorMappers registers mappers which convert endpoint errors to JSON
+// RPC errors. Mappers are tried in order, the first non-nil result wins.
+// Errors not handled by mappers are converted by ErrorCoder and ErrorDataer.
+func ServerErrorMappers(mappers ...ErrorMapper) ServerOption {
+	return func(s *Server) { s.errorMappers = append(s.errorMappers, mappers...) }
+}
+
+// ServerHideInternalErrors replaces message and data of InternalError
+// responses with the generic spec message, so internal details are not
+// exposed to clients in production.
+func ServerHideInternalErrors() ServerOption {
+	return func(s *Server) { s.hideInternal = true }
+}
+
+// rpcError converts the error to a JSON RPC error object.
+func (s Server) rpcError(ctx context.Context, err error) (rpcErr *Error) {
+
+	for _, mapper := range s.errorMappers {
+		if rpcErr = mapper(ctx, err); rpcErr != nil {
+			break
+		}
+	}
+
+	if rpcErr == nil {
+		rpcErr = codedError(err, InternalError)
+	}
+
+	if s.hideInternal && rpcErr.Code == InternalError {
+		log.WithError(err).Error("internal error")
+		rpcErr = &Error{
+			Code:    InternalError,
+			Message: ErrorMessage(InternalError),
+		}
+	}
+	return
+}
+
+// codedError converts the error to a JSON RPC error object using code
+// when the error does not implement ErrorCoder.
+func codedError(err error, code int) *Error {
+
+	var rpcErr Error
+
+	if errors.As(err, &rpcErr) {
+		return &rpcErr
+	}
+
+	rpcErr = Error{
+		Code:    code,
+		Message: err.Error(),
+	}
+
+	var coder ErrorCoder
+
+	if errors.As(err, &coder) {
+		rpcErr.Code = coder.ErrorCode()
+	}
+
+	var dataer ErrorDataer
+
+	if errors.As(err, &dataer) {
+		rpcErr.Data = dataer.ErrorData()
+	}
+	return &rpcErr
+}
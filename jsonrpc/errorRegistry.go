@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// errorRaw is a JSON RPC error object with undecoded data.
+type errorRaw struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type errorFactory func(codec Codec, rpcErr errorRaw) (err error)
+
+// ErrorRegistry maps JSON RPC error codes to Go errors on the client side,
+// so errors returned by the server round-trip into the same typed Go errors
+// and errors.Is / errors.As work for them.
+type ErrorRegistry struct {
+	lock      sync.RWMutex
+	factories map[int]errorFactory
+}
+
+// NewErrorRegistry constructs an empty error registry.
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{factories: make(map[int]errorFactory)}
+}
+
+// Register registers the type of proto for the code. A new value of the type
+// is created for each decoded error: string based errors get the message of
+// the JSON RPC error, other types are unmarshalled from its data field.
+func (r *ErrorRegistry) Register(code int, proto error) {
+
+	errType := reflect.TypeOf(proto)
+
+	r.register(code, func(codec Codec, rpcErr errorRaw) (err error) {
+
+		var value reflect.Value
+
+		if errType.Kind() == reflect.Ptr {
+			value = reflect.New(errType.Elem())
+		} else {
+			value = reflect.New(errType)
+		}
+
+		target := reflect.Indirect(value)
+
+		if target.Kind() == reflect.String {
+			target.SetString(rpcErr.Message)
+		} else if len(rpcErr.Data) != 0 {
+			if err = codec.Unmarshal(rpcErr.Data, value.Interface()); err != nil {
+				return fmt.Errorf("decode data of error %d: %s", rpcErr.Code, err)
+			}
+		}
+
+		if errType.Kind() == reflect.Ptr {
+			return value.Interface().(error)
+		}
+		return target.Interface().(error)
+	})
+}
+
+// RegisterSentinel registers the sentinel error for the code, which is
+// returned as is, so errors.Is(err, sentinel) works on the client side.
+func (r *ErrorRegistry) RegisterSentinel(code int, sentinel error) {
+	r.register(code, func(Codec, errorRaw) error { return sentinel })
+}
+
+func (r *ErrorRegistry) register(code int, factory errorFactory) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.factories[code] = factory
+}
+
+// decode converts the JSON RPC error to the registered Go error, error data
+// is unmarshalled with the codec. Unregistered codes are returned as Error.
+func (r *ErrorRegistry) decode(codec Codec, rpcErr errorRaw) error {
+
+	r.lock.RLock()
+	factory, found := r.factories[rpcErr.Code]
+	r.lock.RUnlock()
+
+	if found {
+		return factory(codec, rpcErr)
+	}
+	return rpcErr.toError(codec)
+}
+
+func (e errorRaw) toError(codec Codec) (rpcErr Error) {
+
+	rpcErr = Error{
+		Code:    e.Code,
+		Message: e.Message,
+	}
+
+	if len(e.Data) != 0 {
+		_ = codec.Unmarshal(e.Data, &rpcErr.Data)
+	}
+	return
+}
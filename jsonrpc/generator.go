@@ -1,9 +1,13 @@
 package jsonrpc
 
 import (
+	"errors"
+
 	"github.com/seniorGolang/gokit/types/uuid"
 )
 
+var errStringID = errors.New("id is a string")
+
 type autoUUIDGeneratorID struct{}
 
 func NewUUIDGenerator() RequestIDGenerator {
@@ -12,6 +16,8 @@ func NewUUIDGenerator() RequestIDGenerator {
 
 func (i *autoUUIDGeneratorID) Generate() *RequestID {
 	return &RequestID{
+		intError:    errStringID,
+		floatError:  errStringID,
 		stringValue: uuid.NewV4().String(),
 	}
 }
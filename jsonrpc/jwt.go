@@ -0,0 +1,270 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// JWTAuthenticator verifies JSON Web Tokens signed with HMAC (HS256, HS384,
+// HS512) or RSA (RS256, RS384, RS512) keys known locally. Keys are selected
+// by the "kid" header of the token, tokens without it use the key with
+// empty id. The subject is taken from "sub", the session from "sid" and
+// permissions from "permissions" array and space separated "scope" claims.
+type JWTAuthenticator struct {
+	secrets    map[string][]byte
+	publicKeys map[string]*rsa.PublicKey
+
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// JWTOption sets an optional parameter for JWT authenticators.
+type JWTOption func(*JWTAuthenticator)
+
+// JWTSecret adds the HMAC secret with the key id.
+func JWTSecret(keyID string, secret []byte) JWTOption {
+	return func(a *JWTAuthenticator) { a.secrets[keyID] = secret }
+}
+
+// JWTPublicKey adds the RSA public key with the key id.
+func JWTPublicKey(keyID string, key *rsa.PublicKey) JWTOption {
+	return func(a *JWTAuthenticator) { a.publicKeys[keyID] = key }
+}
+
+// JWTIssuer requires the "iss" claim to be equal to issuer.
+func JWTIssuer(issuer string) JWTOption {
+	return func(a *JWTAuthenticator) { a.issuer = issuer }
+}
+
+// JWTAudience requires the "aud" claim to contain audience.
+func JWTAudience(audience string) JWTOption {
+	return func(a *JWTAuthenticator) { a.audience = audience }
+}
+
+// JWTLeeway allows the clock skew checking "exp" and "nbf" claims.
+func JWTLeeway(leeway time.Duration) JWTOption {
+	return func(a *JWTAuthenticator) { a.leeway = leeway }
+}
+
+// NewJWTAuthenticator constructs an authenticator of JSON Web Tokens.
+func NewJWTAuthenticator(options ...JWTOption) *JWTAuthenticator {
+
+	a := &JWTAuthenticator{
+		secrets:    make(map[string][]byte),
+		publicKeys: make(map[string]*rsa.PublicKey),
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// Authenticate implements Authenticator.
+func (a *JWTAuthenticator) Authenticate(_ context.Context, token string) (claims Claims, err error) {
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	if err = decodeSegment(parts[0], &header); err != nil {
+		return
+	}
+
+	if err = a.verify(header.Algorithm, header.KeyID, token[:len(parts[0])+1+len(parts[1])], parts[2]); err != nil {
+		return
+	}
+
+	var payload struct {
+		Subject     string          `json:"sub"`
+		SessionID   string          `json:"sid"`
+		Issuer      string          `json:"iss"`
+		Audience    json.RawMessage `json:"aud"`
+		ExpiresAt   *float64        `json:"exp"`
+		NotBefore   *float64        `json:"nbf"`
+		Scope       string          `json:"scope"`
+		Permissions []string        `json:"permissions"`
+	}
+
+	if err = decodeSegment(parts[1], &payload); err != nil {
+		return
+	}
+
+	if err = decodeSegment(parts[1], &claims.Extra); err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	if payload.ExpiresAt != nil {
+		if claims.ExpiresAt = unixTime(*payload.ExpiresAt); now.After(claims.ExpiresAt.Add(a.leeway)) {
+			return Claims{}, errors.New("token is expired")
+		}
+	}
+
+	if payload.NotBefore != nil && now.Add(a.leeway).Before(unixTime(*payload.NotBefore)) {
+		return Claims{}, errors.New("token is not valid yet")
+	}
+
+	if a.issuer != "" && payload.Issuer != a.issuer {
+		return Claims{}, errors.New("unexpected issuer")
+	}
+
+	if a.audience != "" && !hasAudience(payload.Audience, a.audience) {
+		return Claims{}, errors.New("unexpected audience")
+	}
+
+	claims.Subject = payload.Subject
+	claims.SessionID = payload.SessionID
+	claims.Permissions = append(payload.Permissions, strings.Fields(payload.Scope)...)
+	return
+}
+
+// verify checks the signature of the signed part of the token.
+func (a *JWTAuthenticator) verify(algorithm, keyID, signed, signature string) (err error) {
+
+	var sig []byte
+
+	if sig, err = base64.RawURLEncoding.DecodeString(signature); err != nil {
+		return errors.New("malformed signature")
+	}
+
+	if len(algorithm) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hash, found := jwtHashes[algorithm[2:]]
+
+	if !found {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	switch algorithm[:2] {
+
+	case "HS":
+		secret, found := a.secrets[keyID]
+		if !found {
+			return fmt.Errorf("unknown key %q", keyID)
+		}
+		mac := hmac.New(hash.New, secret)
+		_, _ = mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case "RS":
+		key, found := a.publicKeys[keyID]
+		if !found {
+			return fmt.Errorf("unknown key %q", keyID)
+		}
+		hasher := hash.New()
+		_, _ = hasher.Write([]byte(signed))
+		if rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), sig) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+// ParseRSAPublicKey parses the PEM encoded RSA public key in PKIX or PKCS #1
+// form, or the public key of the certificate.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	var err error
+
+	switch block.Type {
+
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+
+	default:
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the "aud" claim, a string or an array of
+// strings, contains the audience.
+func hasAudience(claim json.RawMessage, audience string) bool {
+
+	var audiences []string
+
+	if firstByte(claim) == '[' {
+		_ = json.Unmarshal(claim, &audiences)
+	} else {
+		var single string
+		_ = json.Unmarshal(claim, &single)
+		audiences = append(audiences, single)
+	}
+
+	for _, aud := range audiences {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,225 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("secret")
+
+// signToken returns the token of claims signed with the HMAC secret.
+func signToken(t *testing.T, header, claims map[string]interface{}, secret []byte) string {
+
+	signed := tokenSegments(t, header, claims)
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func tokenSegments(t *testing.T, header, claims map[string]interface{}) string {
+
+	segments := make([]string, 0, 2)
+
+	for _, part := range []map[string]interface{}{header, claims} {
+
+		data, err := json.Marshal(part)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(data))
+	}
+	return strings.Join(segments, ".")
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+
+	now := time.Now().Unix()
+	hs256 := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+
+	auth := NewJWTAuthenticator(
+		JWTSecret("", testSecret),
+		JWTSecret("other", []byte("other secret")),
+		JWTIssuer("issuer"),
+		JWTAudience("api"),
+	)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", signToken(t, hs256, map[string]interface{}{"sub": "user", "iss": "issuer", "aud": "api", "exp": now + 60}, testSecret), true},
+		{"audience list", signToken(t, hs256, map[string]interface{}{"iss": "issuer", "aud": []string{"web", "api"}}, testSecret), true},
+		{"key id", signToken(t, map[string]interface{}{"alg": "HS256", "kid": "other"}, map[string]interface{}{"iss": "issuer", "aud": "api"}, []byte("other secret")), true},
+		{"unknown key id", signToken(t, map[string]interface{}{"alg": "HS256", "kid": "missing"}, map[string]interface{}{"iss": "issuer", "aud": "api"}, testSecret), false},
+		{"wrong secret", signToken(t, hs256, map[string]interface{}{"iss": "issuer", "aud": "api"}, []byte("wrong")), false},
+		{"expired", signToken(t, hs256, map[string]interface{}{"iss": "issuer", "aud": "api", "exp": now - 60}, testSecret), false},
+		{"not valid yet", signToken(t, hs256, map[string]interface{}{"iss": "issuer", "aud": "api", "nbf": now + 60}, testSecret), false},
+		{"wrong issuer", signToken(t, hs256, map[string]interface{}{"iss": "other", "aud": "api"}, testSecret), false},
+		{"wrong audience", signToken(t, hs256, map[string]interface{}{"iss": "issuer", "aud": "web"}, testSecret), false},
+		{"unsigned", tokenSegments(t, map[string]interface{}{"alg": "none"}, map[string]interface{}{"iss": "issuer", "aud": "api"}) + ".", false},
+		{"malformed", "token", false},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			if _, err := auth.Authenticate(context.Background(), test.token); (err == nil) != test.valid {
+				t.Errorf("error %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := signToken(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{
+		"sub":         "user",
+		"sid":         "session",
+		"exp":         exp,
+		"scope":       "read write",
+		"permissions": []string{"admin"},
+		"tenant":      "acme",
+	}, testSecret)
+
+	claims, err := NewJWTAuthenticator(JWTSecret("", testSecret)).Authenticate(context.Background(), token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "user" || claims.SessionID != "session" || claims.ExpiresAt.Unix() != exp {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	for _, permission := range []string{"admin", "read", "write"} {
+		if !claims.HasPermission(permission) {
+			t.Errorf("permission %q is not granted: %v", permission, claims.Permissions)
+		}
+	}
+
+	if claims.Extra["tenant"] != "acme" {
+		t.Errorf("extra claims %v", claims.Extra)
+	}
+}
+
+func TestJWTLeeway(t *testing.T) {
+
+	token := signToken(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{"exp": time.Now().Add(-time.Second).Unix()}, testSecret)
+
+	if _, err := NewJWTAuthenticator(JWTSecret("", testSecret), JWTLeeway(time.Minute)).Authenticate(context.Background(), token); err != nil {
+		t.Errorf("token within leeway is rejected: %v", err)
+	}
+}
+
+func TestJWTRSA(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := tokenSegments(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, map[string]interface{}{"sub": "service"})
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := NewJWTAuthenticator(JWTPublicKey("rsa", publicKey), JWTSecret("rsa", testSecret))
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	if claims, err := auth.Authenticate(context.Background(), token); err != nil || claims.Subject != "service" {
+		t.Errorf("claims %+v, error %v", claims, err)
+	}
+
+	// the public key must not be usable as the HMAC secret of the same key id
+	forged := signToken(t, map[string]interface{}{"alg": "HS256", "kid": "rsa"}, map[string]interface{}{"sub": "service"}, der)
+
+	if _, err = auth.Authenticate(context.Background(), forged); err == nil {
+		t.Error("token signed with the public key is accepted")
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+
+	s, _ := newTestServer(t,
+		ServerAuthenticator(NewJWTAuthenticator(JWTSecret("", testSecret))),
+		ServerPermissions("fail", "admin"),
+		ServerPublicMethods("echo"),
+	)
+
+	header := map[string]interface{}{"alg": "HS256"}
+	user := signToken(t, header, map[string]interface{}{"sub": "user"}, testSecret)
+	admin := signToken(t, header, map[string]interface{}{"sub": "admin", "scope": "admin"}, testSecret)
+	forged := signToken(t, header, map[string]interface{}{"sub": "admin", "scope": "admin"}, []byte("wrong"))
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		code   int
+	}{
+		{"public method", "echo", "", 0},
+		{"anonymous", "fail", "", UnauthorizedError},
+		{"invalid token", "fail", forged, UnauthorizedError},
+		{"no permission", "fail", user, ForbiddenError},
+		{"permitted", "fail", admin, InternalError},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"`+test.method+`","params":{"a":1},"id":1}`))
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+
+			resp := decodeSingle(t, w.Body.String())
+
+			if test.code == 0 && resp.Error != nil {
+				t.Errorf("unexpected error %v", resp.Error)
+			}
+
+			if test.code != 0 && (resp.Error == nil || resp.Error.Code != test.code) {
+				t.Errorf("error %v, want code %d", resp.Error, test.code)
+			}
+		})
+	}
+}
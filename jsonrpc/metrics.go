@@ -0,0 +1,336 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serverSide = "server"
+	clientSide = "client"
+
+	// unknownMethod labels calls to methods missing on the server,
+	// so arbitrary method names don't produce new series.
+	unknownMethod = "unknown"
+
+	// transportCode labels errors without JSON RPC code, e.g. network
+	// errors of the client or responses which could not be decoded.
+	transportCode = "transport"
+
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	defaultBatchBuckets   = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500}
+)
+
+// Metrics collects metrics of servers and clients and exposes them in the
+// Prometheus text format. For each side ("server" or "client") it records
+//
+//	jsonrpc_<side>_requests_total{method}
+//	jsonrpc_<side>_errors_total{method,code}
+//	jsonrpc_<side>_request_duration_seconds{method}
+//	jsonrpc_<side>_batch_size{method}
+//
+// The batch size of a method is the number of its calls in a single batch.
+// Metrics implements http.Handler, so it can be mounted on the router
+// served by server.StartHttpServer, e.g. router.Handle("/metrics", metrics).
+// A single Metrics may be shared by any number of servers and clients.
+type Metrics struct {
+	namespace      string
+	latencyBuckets []float64
+	batchBuckets   []float64
+
+	lock     sync.Mutex
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	kind    string
+	help    string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels string
+	value  float64
+	counts []uint64
+}
+
+// MetricsOption sets an optional parameter for metrics.
+type MetricsOption func(*Metrics)
+
+// MetricsNamespace prefixes names of metrics with the namespace.
+func MetricsNamespace(namespace string) MetricsOption {
+	return func(m *Metrics) { m.namespace = namespace }
+}
+
+// MetricsLatencyBuckets sets upper bounds in seconds of latency histogram buckets.
+func MetricsLatencyBuckets(buckets ...float64) MetricsOption {
+	return func(m *Metrics) { m.latencyBuckets = sortedBuckets(buckets) }
+}
+
+// MetricsBatchBuckets sets upper bounds of batch size histogram buckets.
+func MetricsBatchBuckets(buckets ...float64) MetricsOption {
+	return func(m *Metrics) { m.batchBuckets = sortedBuckets(buckets) }
+}
+
+// NewMetrics constructs an empty set of metrics.
+func NewMetrics(options ...MetricsOption) *Metrics {
+
+	m := &Metrics{
+		latencyBuckets: defaultLatencyBuckets,
+		batchBuckets:   defaultBatchBuckets,
+		families:       make(map[string]*metricFamily),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// ServerMetrics records metrics of calls served by the server.
+// Calls to unknown methods are recorded with the "unknown" method.
+func ServerMetrics(metrics *Metrics) ServerOption {
+	return func(s *Server) { s.metrics = metrics }
+}
+
+// ClientMetrics records metrics of calls made by the client.
+// Errors without JSON RPC code are recorded with the "transport" code.
+func ClientMetrics(metrics *Metrics) ClientOption {
+	return func(c *Client) { c.metrics = metrics }
+}
+
+// ServeHTTP implements http.Handler and writes metrics in the text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+
+	w.Header().Set("Content-Type", metricsContentType)
+
+	if _, err := m.WriteTo(w); err != nil {
+		log.WithError(err).Debug("write metrics error")
+	}
+}
+
+// WriteTo writes metrics to w in the Prometheus text format. Metrics are
+// rendered under the lock, so slow scrapers don't block observed calls.
+func (m *Metrics) WriteTo(w io.Writer) (n int64, err error) {
+
+	var buf bytes.Buffer
+	m.render(&buf)
+	return buf.WriteTo(w)
+}
+
+// render writes the snapshot of metrics to buf.
+func (m *Metrics) render(buf *bytes.Buffer) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.families))
+
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		family := m.families[name]
+
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+
+		series := make([]*metricSeries, 0, len(family.series))
+
+		for _, s := range family.series {
+			series = append(series, s)
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+
+		for _, s := range series {
+
+			if family.kind == "counter" {
+				fmt.Fprintf(buf, "%s{%s} %s\n", name, s.labels, formatFloat(s.value))
+				continue
+			}
+
+			var cumulative uint64
+
+			for i, bound := range family.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, s.labels, formatFloat(bound), cumulative)
+			}
+
+			cumulative += s.counts[len(family.buckets)]
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, s.labels, cumulative)
+			fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, s.labels, formatFloat(s.value))
+			fmt.Fprintf(buf, "%s_count{%s} %d\n", name, s.labels, cumulative)
+		}
+	}
+
+}
+
+// observeCall records the completed call, empty code means success.
+func (m *Metrics) observeCall(side, method, code string, duration time.Duration) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	labels := labelPairs("method", method)
+
+	m.counter(side, "requests_total", "Total number of JSON RPC calls.", labels).value++
+
+	if code != "" {
+		m.counter(side, "errors_total", "Total number of JSON RPC calls failed, by error code.", labelPairs("method", method, "code", code)).value++
+	}
+
+	m.histogram(side, "request_duration_seconds", "Latency of JSON RPC calls in seconds.", m.latencyBuckets, labels).observe(m.latencyBuckets, duration.Seconds())
+}
+
+// observeBatch records the number of calls of each method in the batch.
+func (m *Metrics) observeBatch(side string, methods []string) {
+
+	sizes := make(map[string]int)
+
+	for _, method := range methods {
+		sizes[method]++
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for method, size := range sizes {
+		m.histogram(side, "batch_size", "Number of calls of the method in a JSON RPC batch.", m.batchBuckets, labelPairs("method", method)).observe(m.batchBuckets, float64(size))
+	}
+}
+
+func (m *Metrics) counter(side, name, help, labels string) *metricSeries {
+	return m.series(side, name, help, "counter", nil, labels)
+}
+
+func (m *Metrics) histogram(side, name, help string, buckets []float64, labels string) *metricSeries {
+	return m.series(side, name, help, "histogram", buckets, labels)
+}
+
+// series returns the series of the family with the labels, creating both
+// if needed. The lock must be held.
+func (m *Metrics) series(side, name, help, kind string, buckets []float64, labels string) *metricSeries {
+
+	name = "jsonrpc_" + side + "_" + name
+
+	if m.namespace != "" {
+		name = m.namespace + "_" + name
+	}
+
+	family, found := m.families[name]
+
+	if !found {
+		family = &metricFamily{
+			kind:    kind,
+			help:    help,
+			buckets: buckets,
+			series:  make(map[string]*metricSeries),
+		}
+		m.families[name] = family
+	}
+
+	series, found := family.series[labels]
+
+	if !found {
+		series = &metricSeries{labels: labels}
+		if kind == "histogram" {
+			series.counts = make([]uint64, len(buckets)+1)
+		}
+		family.series[labels] = series
+	}
+	return series
+}
+
+// observe adds the value to the histogram, the last count is the +Inf bucket.
+func (s *metricSeries) observe(buckets []float64, value float64) {
+
+	s.value += value
+	s.counts[sort.SearchFloat64s(buckets, value)]++
+}
+
+// methodLabel returns the label of the method, which is unknownMethod for
+// methods missing on the server.
+func (s Server) methodLabel(method string) string {
+
+	if _, found := s.ecm[method]; !found {
+		return unknownMethod
+	}
+	return method
+}
+
+// responseCode returns the error code label of the server response.
+func responseCode(resp *Response) string {
+
+	if resp == nil || resp.Error == nil {
+		return ""
+	}
+	return strconv.Itoa(resp.Error.Code)
+}
+
+// callCode returns the error code label of the client call. rpcErr is the
+// raw error of the response, if it was received.
+func callCode(rpcErr json.RawMessage, err error) string {
+
+	if err == nil {
+		return ""
+	}
+
+	var coded struct {
+		Code *int `json:"code"`
+	}
+
+	if len(rpcErr) == 0 || json.Unmarshal(rpcErr, &coded) != nil || coded.Code == nil {
+		return transportCode
+	}
+	return strconv.Itoa(*coded.Code)
+}
+
+// labelPairs formats label names and values, values are escaped.
+func labelPairs(pairs ...string) string {
+
+	var b strings.Builder
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+
+	if math.IsInf(value, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedBuckets(buckets []float64) []float64 {
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return sorted
+}
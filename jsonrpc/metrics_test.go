@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks writes until unblock is closed.
+type blockingWriter struct {
+	started chan struct{}
+	unblock chan struct{}
+	data    strings.Builder
+}
+
+func (w *blockingWriter) Write(data []byte) (int, error) {
+
+	close(w.started)
+	<-w.unblock
+	return w.data.Write(data)
+}
+
+func TestMetricsSlowScraper(t *testing.T) {
+
+	m := NewMetrics()
+	m.observeCall(serverSide, "echo", "", time.Millisecond)
+
+	w := &blockingWriter{started: make(chan struct{}), unblock: make(chan struct{})}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_, _ = m.WriteTo(w)
+	}()
+	<-w.started
+
+	observed := make(chan struct{})
+
+	go func() {
+		m.observeCall(serverSide, "echo", "", time.Millisecond)
+		close(observed)
+	}()
+
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Error("call is not observed while metrics are written")
+	}
+
+	close(w.unblock)
+	<-done
+
+	if !strings.Contains(w.data.String(), `requests_total{method="echo"} 1`) {
+		t.Errorf("unexpected metrics %s", w.data.String())
+	}
+}
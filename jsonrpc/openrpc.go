@@ -0,0 +1,363 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// OpenRPCVersion defines the version of the OpenRPC specification of generated documents.
+	OpenRPCVersion = "1.2.6"
+
+	// DiscoverMethod is the method serving the OpenRPC document of the server.
+	DiscoverMethod = "rpc.discover"
+
+	// gkgTag is the struct tag describing fields in the same format as
+	// @gkg comments, e.g. `gkg:"example=43.116418 desc=latitude"`. Comments
+	// are not available at run time, so the tag repeats the @gkg comment.
+	gkgTag = "gkg"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// OpenRPC is the OpenRPC document describing methods of the server,
+// see https://spec.open-rpc.org
+type OpenRPC struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []OpenRPCMethod   `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+// OpenRPCInfo defines metadata about the API.
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenRPCMethod describes a single method of the API.
+type OpenRPCMethod struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         *ContentDescriptor  `json:"result,omitempty"`
+	ParamStructure string              `json:"paramStructure,omitempty"`
+}
+
+// ContentDescriptor describes params and results of methods.
+type ContentDescriptor struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// OpenRPCComponents holds schemas of named struct types referenced by methods.
+type OpenRPCComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is a subset of JSON Schema used to describe Go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Examples             []interface{}      `json:"examples,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// ServerDiscovery serves the OpenRPC document generated from the endpoint
+// codecs of the server with the rpc.discover method. Types of methods are
+// taken from Params and Result of EndpointCodec.
+func ServerDiscovery(info OpenRPCInfo) ServerOption {
+	return func(s *Server) {
+
+		ecm := make(EndpointCodecMap, len(s.ecm)+1)
+
+		for method, codec := range s.ecm {
+			ecm[method] = codec
+		}
+
+		document := NewOpenRPC(info, s.ecm)
+
+		ecm[DiscoverMethod] = EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) { return document, nil },
+			Decode:   func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+			Encode: func(ctx context.Context, response interface{}) (json.RawMessage, error) {
+				return CodecFromContext(ctx).Marshal(response)
+			},
+			Result:  document,
+			Summary: "Returns the OpenRPC document of the service.",
+		}
+		s.ecm = ecm
+	}
+}
+
+// NewOpenRPC generates the OpenRPC document of the methods.
+func NewOpenRPC(info OpenRPCInfo, ecm EndpointCodecMap) OpenRPC {
+
+	g := schemaGenerator{schemas: make(map[string]*Schema)}
+
+	document := OpenRPC{
+		OpenRPC: OpenRPCVersion,
+		Info:    info,
+		Methods: make([]OpenRPCMethod, 0, len(ecm)),
+	}
+
+	for name, codec := range ecm {
+
+		method := OpenRPCMethod{
+			Name:    name,
+			Summary: codec.Summary,
+			Params:  []ContentDescriptor{},
+		}
+
+		if codec.Params != nil {
+			method.ParamStructure = "either"
+			method.Params = g.params(reflect.TypeOf(codec.Params))
+		}
+
+		if codec.Result != nil {
+			method.Result = &ContentDescriptor{
+				Name:   "result",
+				Schema: g.schema(reflect.TypeOf(codec.Result)),
+			}
+		}
+		document.Methods = append(document.Methods, method)
+	}
+
+	sort.Slice(document.Methods, func(i, j int) bool {
+		return document.Methods[i].Name < document.Methods[j].Name
+	})
+
+	document.Components.Schemas = g.schemas
+	return document
+}
+
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+// params describes fields of struct params in positional order,
+// other types are described as a single param.
+func (g *schemaGenerator) params(t reflect.Type) (params []ContentDescriptor) {
+
+	params = make([]ContentDescriptor, 0)
+	t = indirectType(t)
+
+	var fields []reflect.StructField
+	var err error
+
+	if t.Kind() == reflect.Struct {
+		fields, err = positionalFields(t)
+	}
+
+	if t.Kind() != reflect.Struct || err != nil {
+		return []ContentDescriptor{{
+			Name:     "params",
+			Required: true,
+			Schema:   g.schema(t),
+		}}
+	}
+
+	for _, field := range fields {
+
+		name, omitEmpty := jsonName(field)
+		schema := g.schema(field.Type)
+		describe(schema, field.Tag.Get(gkgTag))
+
+		params = append(params, ContentDescriptor{
+			Name:        name,
+			Description: schema.Description,
+			Required:    !omitEmpty && field.Type.Kind() != reflect.Ptr,
+			Schema:      schema,
+		})
+	}
+	return
+}
+
+// schema describes the type, named structs are described in components
+// and referenced, so recursive types are supported.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+
+	t = indirectType(t)
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := strings.Replace(t.String(), ".", "_", -1)
+		if _, found := g.schemas[name]; !found {
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// object describes struct fields as properties.
+func (g *schemaGenerator) object(t reflect.Type) *Schema {
+
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, omitEmpty := jsonName(field)
+
+		if name == "-" {
+			continue
+		}
+
+		// fields of embedded structs are promoted like encoding/json does
+		if embedded := indirectType(field.Type); field.Anonymous && field.Tag.Get("json") == "" && embedded.Kind() == reflect.Struct {
+			promoted := g.object(embedded)
+			for key, property := range promoted.Properties {
+				schema.Properties[key] = property
+			}
+			schema.Required = append(schema.Required, promoted.Required...)
+			continue
+		}
+
+		property := g.schema(field.Type)
+		describe(property, field.Tag.Get(gkgTag))
+
+		schema.Properties[name] = property
+
+		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// jsonName returns the name of the field in JSON and whether it may be omitted.
+func jsonName(field reflect.StructField) (name string, omitEmpty bool) {
+
+	options := strings.Split(field.Tag.Get("json"), ",")
+
+	if name = options[0]; name == "" {
+		name = field.Name
+	}
+
+	for _, option := range options[1:] {
+		omitEmpty = omitEmpty || option == "omitempty"
+	}
+	return
+}
+
+// describe sets description and example of the schema from gkg tag.
+// References are described by siblings, which is allowed by OpenRPC tools.
+func describe(schema *Schema, tag string) {
+
+	for key, value := range parseGkgTag(tag) {
+		switch key {
+		case "desc":
+			schema.Description = value
+		case "example":
+			var example interface{}
+			if err := json.Unmarshal([]byte(value), &example); err != nil {
+				example = value
+			}
+			schema.Examples = append(schema.Examples, example)
+		}
+	}
+}
+
+// parseGkgTag parses key=value pairs separated by spaces,
+// values with spaces must be double quoted.
+func parseGkgTag(tag string) (values map[string]string) {
+
+	values = make(map[string]string)
+
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+
+		eq := strings.IndexByte(tag, '=')
+
+		if eq <= 0 {
+			return
+		}
+
+		key, rest := tag[:eq], tag[eq+1:]
+
+		var value string
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return
+			}
+			value, tag = rest[1:end+1], rest[end+2:]
+		} else if end := strings.IndexByte(rest, ' '); end >= 0 {
+			value, tag = rest[:end], rest[end:]
+		} else {
+			value, tag = rest, ""
+		}
+		values[key] = value
+	}
+	return
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,103 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/seniorGolang/gokit/types/geo"
+)
+
+type emptyParams struct{}
+
+type discoveryService struct{}
+
+func (discoveryService) Count(_ context.Context, n int) (int, error) {
+	return n, nil
+}
+
+func (discoveryService) Ping(_ context.Context, _ emptyParams) (bool, error) {
+	return true, nil
+}
+
+func (discoveryService) Locate(_ context.Context, point geo.Point) (*geo.Point, error) {
+	return &point, nil
+}
+
+func TestServerDiscovery(t *testing.T) {
+
+	ecm, err := MakeEndpointCodecMap("", discoveryService{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(ecm, ServerDiscovery(OpenRPCInfo{Title: "discovery", Version: "1.0.0"}))
+
+	resp := decodeSingle(t, serve(t, s, `{"jsonrpc":"2.0","method":"rpc.discover","id":1}`))
+
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	var document struct {
+		OpenRPC string `json:"openrpc"`
+		Info    OpenRPCInfo
+		Methods []struct {
+			Name   string             `json:"name"`
+			Params *[]json.RawMessage `json:"params"`
+		} `json:"methods"`
+	}
+
+	if err = json.Unmarshal(resp.Result, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if document.OpenRPC != OpenRPCVersion || document.Info.Title != "discovery" {
+		t.Errorf("unexpected document %s", resp.Result)
+	}
+
+	params := make(map[string]int)
+
+	for _, method := range document.Methods {
+		if method.Params == nil {
+			t.Errorf("method %s: params are null", method.Name)
+			continue
+		}
+		params[method.Name] = len(*method.Params)
+	}
+
+	want := map[string]int{"count": 1, "ping": 0, "locate": 2}
+
+	for method, count := range want {
+		if got, found := params[method]; !found || got != count {
+			t.Errorf("method %s: %d params, want %d", method, got, count)
+		}
+	}
+}
+
+func TestOpenRPCDescriptions(t *testing.T) {
+
+	ecm, err := MakeEndpointCodecMap("", discoveryService{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document := NewOpenRPC(OpenRPCInfo{Title: "discovery"}, ecm)
+
+	for _, method := range document.Methods {
+
+		if method.Name != "locate" {
+			continue
+		}
+
+		lat := method.Params[0]
+
+		if lat.Name != "lat" || lat.Description != "latitude" || len(lat.Schema.Examples) != 1 || lat.Schema.Examples[0] != 43.116418 {
+			t.Errorf("unexpected lat param %+v, schema %+v", lat, lat.Schema)
+		}
+		return
+	}
+	t.Error("method locate is not described")
+}
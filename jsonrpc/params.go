@@ -0,0 +1,208 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// positionTag is the struct tag which declares the position of a field in
+// by-position params, e.g. `position:"0"`. When no field of a struct has the
+// tag, all exported JSON fields are used in declared order.
+const positionTag = "position"
+
+// DecodeParams decodes JSON RPC params into the value pointed by v.
+// Params may be passed by-name as an object or by-position as an array.
+// Positional params are mapped onto struct fields by their positions,
+// a single positional param is decoded directly into non-struct values.
+func DecodeParams(params json.RawMessage, v interface{}) (err error) {
+	return decodeParams(DefaultCodec, params, v, false)
+}
+
+// DecodeStrictParams decodes params like DecodeParams, but rejects
+// object fields unknown to the target type.
+func DecodeStrictParams(params json.RawMessage, v interface{}) (err error) {
+	return decodeParams(DefaultCodec, params, v, true)
+}
+
+func decodeParams(codec Codec, params json.RawMessage, v interface{}, strict bool) (err error) {
+
+	if firstByte(params) != '[' {
+		if len(params) == 0 {
+			return
+		}
+		return unmarshal(codec, params, v, strict)
+	}
+
+	value := reflect.ValueOf(v)
+
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("params target must be a non-nil pointer, got %T", v)
+	}
+
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+
+	case reflect.Slice, reflect.Array, reflect.Interface:
+		return unmarshal(codec, params, value.Addr().Interface(), strict)
+
+	case reflect.Struct:
+		var positional []json.RawMessage
+		if err = codec.Unmarshal(params, &positional); err != nil {
+			return
+		}
+		fields, err := positionalFields(value.Type())
+		if err != nil {
+			return err
+		}
+		if len(positional) > len(fields) {
+			return fmt.Errorf("too many positional params: %d, expected at most %d", len(positional), len(fields))
+		}
+		for i, param := range positional {
+			if err = unmarshal(codec, param, value.FieldByIndex(fields[i].Index).Addr().Interface(), strict); err != nil {
+				return fmt.Errorf("param %d (%s): %s", i, fields[i].Name, err)
+			}
+		}
+		return nil
+
+	default:
+		var positional []json.RawMessage
+		if err = codec.Unmarshal(params, &positional); err != nil {
+			return
+		}
+		if len(positional) != 1 {
+			return fmt.Errorf("expected exactly one positional param, got %d", len(positional))
+		}
+		return unmarshal(codec, positional[0], value.Addr().Interface(), strict)
+	}
+}
+
+// unmarshal decodes data into v, rejecting unknown object fields if strict.
+func unmarshal(codec Codec, data []byte, v interface{}, strict bool) error {
+
+	if !strict {
+		return codec.Unmarshal(data, v)
+	}
+
+	if strictCodec, ok := codec.(StrictUnmarshaler); ok {
+		return strictCodec.UnmarshalStrict(data, v)
+	}
+	return JSONCodec{}.UnmarshalStrict(data, v)
+}
+
+// EncodeParams encodes v as JSON RPC params. By-name params are encoded as
+// regular JSON, by-position params of a struct are encoded as an array of
+// its fields ordered by their positions. Non-struct values are wrapped into
+// a single element array unless they are slices already.
+func EncodeParams(v interface{}, byPosition bool) (params json.RawMessage, err error) {
+	return encodeParams(DefaultCodec, v, byPosition)
+}
+
+func encodeParams(codec Codec, v interface{}, byPosition bool) (params json.RawMessage, err error) {
+
+	if !byPosition || v == nil {
+		return codec.Marshal(v)
+	}
+
+	value := reflect.ValueOf(v)
+
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return codec.Marshal(nil)
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+
+	case reflect.Slice, reflect.Array:
+		return codec.Marshal(v)
+
+	case reflect.Struct:
+		fields, err := positionalFields(value.Type())
+		if err != nil {
+			return nil, err
+		}
+		positional := make([]interface{}, len(fields))
+		for i, field := range fields {
+			positional[i] = value.FieldByIndex(field.Index).Interface()
+		}
+		return codec.Marshal(positional)
+
+	default:
+		return codec.Marshal([]interface{}{v})
+	}
+}
+
+// MakeParamsDecoder returns DecodeRequestFunc which decodes by-name or
+// by-position params into a new value of the same type as proto.
+func MakeParamsDecoder(proto interface{}) DecodeRequestFunc {
+	return makeParamsDecoder(reflect.TypeOf(proto))
+}
+
+func makeParamsDecoder(reqType reflect.Type) DecodeRequestFunc {
+	return func(ctx context.Context, params json.RawMessage) (request interface{}, err error) {
+
+		value := reflect.New(reqType)
+
+		if err = decodeParams(CodecFromContext(ctx), params, value.Interface(), StrictParams(ctx)); err != nil {
+			return
+		}
+		return value.Elem().Interface(), nil
+	}
+}
+
+// PositionalRequestEncoder encodes client request as by-position params.
+func PositionalRequestEncoder(ctx context.Context, req interface{}) (json.RawMessage, error) {
+	return encodeParams(CodecFromContext(ctx), req, true)
+}
+
+// positionalFields returns struct fields ordered by their positions.
+func positionalFields(t reflect.Type) (fields []reflect.StructField, err error) {
+
+	var tagged []reflect.StructField
+	positions := make(map[string]int)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		fields = append(fields, field)
+
+		if tag, found := field.Tag.Lookup(positionTag); found {
+
+			if positions[field.Name], err = strconv.Atoi(tag); err != nil {
+				return nil, fmt.Errorf("%s.%s: invalid position %q", t, field.Name, tag)
+			}
+			tagged = append(tagged, field)
+		}
+	}
+
+	if len(tagged) == 0 {
+		return
+	}
+
+	sort.SliceStable(tagged, func(i, j int) bool {
+		return positions[tagged[i].Name] < positions[tagged[j].Name]
+	})
+
+	for i, field := range tagged {
+		if positions[field.Name] != i {
+			return nil, fmt.Errorf("%s: positions must be sequential from 0, field %s has %d", t, field.Name, positions[field.Name])
+		}
+	}
+	return tagged, nil
+}
@@ -1,14 +1,67 @@
 package jsonrpc
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+)
 
 // Request defines a JSON RPC request from the spec
 // http://www.jsonrpc.org/specification#request_object
 type Request struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params"`
-	ID      *RequestID      `json:"id"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      *RequestID      `json:"id,omitempty"`
+}
+
+// UnmarshalJSON satisfies json.Unmarshaler.
+// Unlike the default decoding it keeps an explicit "id": null, so such a
+// request is not mistaken for a notification.
+func (r *Request) UnmarshalJSON(b []byte) (err error) {
+
+	var raw struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+		ID      json.RawMessage `json:"id"`
+	}
+
+	if err = DefaultCodec.Unmarshal(b, &raw); err != nil {
+		return
+	}
+
+	*r = Request{
+		JSONRPC: raw.JSONRPC,
+		Method:  raw.Method,
+		Params:  raw.Params,
+	}
+
+	if raw.ID != nil {
+		r.ID = new(RequestID)
+		return r.ID.UnmarshalJSON(raw.ID)
+	}
+	return
+}
+
+// validate checks the request object against the JSON RPC 2.0 spec.
+func (r *Request) validate() error {
+
+	if r.JSONRPC != Version {
+		return invalidRequestError("jsonrpc must be exactly \"" + Version + "\"")
+	}
+
+	if r.Method == "" {
+		return invalidRequestError("method must be a non-empty string")
+	}
+
+	// null params are tolerated as omitted ones, since clients encode
+	// a nil request this way
+	switch firstByte(r.Params) {
+	case 0, '{', '[', 'n':
+	default:
+		return invalidRequestError("params must be an object or an array")
+	}
+	return nil
 }
 
 // RequestID defines a request ID that can be string, number, or null.
@@ -18,6 +71,7 @@ type Request struct {
 // The value SHOULD normally not be Null and
 // Numbers SHOULD NOT contain fractional parts.
 type RequestID struct {
+	null        bool
 	intValue    int
 	intError    error
 	floatValue  float32
@@ -26,25 +80,61 @@ type RequestID struct {
 	stringError error
 }
 
+var (
+	errNullID         = errors.New("id is null")
+	errInvalidIDValue = invalidRequestError("id must be a string, a number or null")
+)
+
 // UnmarshalJSON satisfies json.Unmarshaler
+// Values other than string, number or null are rejected with InvalidRequestError.
 func (id *RequestID) UnmarshalJSON(b []byte) error {
-	id.intError = json.Unmarshal(b, &id.intValue)
-	id.floatError = json.Unmarshal(b, &id.floatValue)
-	id.stringError = json.Unmarshal(b, &id.stringValue)
+
+	switch c := firstByte(b); {
+
+	case c == 'n' && string(b) == "null":
+		*id = RequestID{
+			null:        true,
+			intError:    errNullID,
+			floatError:  errNullID,
+			stringError: errNullID,
+		}
+		return nil
+
+	case c != '"' && c != '-' && (c < '0' || c > '9'):
+		return errInvalidIDValue
+	}
+
+	*id = RequestID{}
+	id.intError = DefaultCodec.Unmarshal(b, &id.intValue)
+	id.floatError = DefaultCodec.Unmarshal(b, &id.floatValue)
+	id.stringError = DefaultCodec.Unmarshal(b, &id.stringValue)
 
 	return nil
 }
 
 func (id *RequestID) MarshalJSON() ([]byte, error) {
-	if id.intError == nil {
-		return json.Marshal(id.intValue)
+	if id.null {
+		return []byte("null"), nil
+	} else if id.intError == nil {
+		return DefaultCodec.Marshal(id.intValue)
 	} else if id.floatError == nil {
-		return json.Marshal(id.floatValue)
+		return DefaultCodec.Marshal(id.floatValue)
 	} else {
-		return json.Marshal(id.stringValue)
+		return DefaultCodec.Marshal(id.stringValue)
 	}
 }
 
+// key returns the JSON representation of the ID to correlate responses.
+func (id *RequestID) key() string {
+
+	if id == nil {
+		return ""
+	}
+
+	data, _ := id.MarshalJSON()
+	return string(data)
+}
+
 // Int returns the ID as an integer value.
 // An error is returned if the ID can't be treated as an int.
 func (id *RequestID) Int() (int, error) {
@@ -73,11 +163,11 @@ type Response struct {
 }
 
 type ResponseRaw struct {
-	ID      *RequestID      `json:"id"`
-	JSONRPC string          `json:"jsonrpc"`
+	ID      *RequestID `json:"id"`
+	JSONRPC string     `json:"jsonrpc"`
 
-	Result  json.RawMessage `json:"result,omitempty"`
-	Error   json.RawMessage `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
 }
 
 const (
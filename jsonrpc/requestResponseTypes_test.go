@@ -0,0 +1,98 @@
+package jsonrpc
+
+import (
+	"testing"
+)
+
+func TestRequestValidation(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+		id   string
+		code int
+	}{
+		{"valid", `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":1}`, "1", 0},
+		{"string id", `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":"abc"}`, `"abc"`, 0},
+		{"null id", `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":null}`, "null", 0},
+		{"missing version", `{"method":"echo","params":{"a":1},"id":1}`, "1", InvalidRequestError},
+		{"wrong version", `{"jsonrpc":"1.0","method":"echo","params":{"a":1},"id":1}`, "1", InvalidRequestError},
+		{"missing method", `{"jsonrpc":"2.0","params":{"a":1},"id":1}`, "1", InvalidRequestError},
+		{"number method", `{"jsonrpc":"2.0","method":1,"id":1}`, "null", InvalidRequestError},
+		{"scalar params", `{"jsonrpc":"2.0","method":"echo","params":1,"id":1}`, "1", InvalidRequestError},
+		{"object id", `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":{}}`, "null", InvalidRequestError},
+		{"bool id", `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":true}`, "null", InvalidRequestError},
+		{"string body", `"request"`, "null", InvalidRequestError},
+		{"empty batch", `[]`, "null", InvalidRequestError},
+		{"invalid json", `{"jsonrpc":"2.0"`, "null", ParseError},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			resp := decodeSingle(t, serve(t, s, test.body))
+
+			if string(resp.ID) != test.id {
+				t.Errorf("id %s, want %s", resp.ID, test.id)
+			}
+
+			if test.code == 0 && resp.Error != nil {
+				t.Errorf("unexpected error %v", resp.Error)
+			}
+
+			if test.code != 0 && (resp.Error == nil || resp.Error.Code != test.code) {
+				t.Errorf("error %v, want code %d", resp.Error, test.code)
+			}
+		})
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	respList := decodeBatch(t, serve(t, s, `[
+		{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":1},
+		5,
+		{"jsonrpc":"1.0","method":"echo","params":{"a":1},"id":3},
+		{"jsonrpc":"2.0","method":"echo","params":{"a":1}}
+	]`))
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", 0},
+		{"null", InvalidRequestError},
+		{"3", InvalidRequestError},
+	}
+
+	if len(respList) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(respList), len(want))
+	}
+
+	for i, w := range want {
+
+		resp := respList[i]
+
+		if string(resp.ID) != w.id {
+			t.Errorf("response %d: id %s, want %s", i, resp.ID, w.id)
+		}
+
+		if (w.code == 0) != (resp.Error == nil) || (resp.Error != nil && resp.Error.Code != w.code) {
+			t.Errorf("response %d: error %v, want code %d", i, resp.Error, w.code)
+		}
+	}
+}
+
+func TestNotificationWithoutResponse(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	if body := serve(t, s, `{"jsonrpc":"2.0","method":"missing"}`); body != "" {
+		t.Errorf("unexpected response to notification: %s", body)
+	}
+}
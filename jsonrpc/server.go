@@ -2,20 +2,18 @@ package jsonrpc
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"sync"
 
 	httpTransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 
 	"github.com/seniorGolang/gokit/logger"
+	"github.com/seniorGolang/gokit/utils"
 )
 
 const reqID = "requestID"
+
 var log = logger.Log.WithField("module", "httpServer")
 
 // Server wraps an endpoint and implements http.Handler.
@@ -23,8 +21,44 @@ type Server struct {
 	ecm          EndpointCodecMap
 	errorEncoder httpTransport.ErrorEncoder
 	before       []httpTransport.RequestFunc
-	finalizer    httpTransport.ServerFinalizerFunc
+	finalizer    []httpTransport.ServerFinalizerFunc
 	after        []httpTransport.ServerResponseFunc
+
+	batchLimit    int
+	batchParallel int
+
+	hideInternal bool
+	errorMappers []ErrorMapper
+
+	tracing bool
+
+	fastBefore []FastRequestFunc
+	fastAfter  []FastResponseFunc
+
+	maxBodySize     int64
+	maxDepth        int
+	maxStringLength int
+	strictParams    bool
+
+	interceptors []Interceptor
+	handler      CallHandler
+
+	metrics *Metrics
+
+	errorStatus func(code int) int
+
+	authenticator Authenticator
+	permissions   map[string][]string
+	publicMethods map[string]bool
+
+	limits map[string]*limits
+
+	streamBatch   bool
+	streamOrdered bool
+
+	compressionThreshold int
+
+	codecs []Codec
 }
 
 // NewServer constructs a new server, which implements http.Server.
@@ -32,10 +66,14 @@ func NewServer(ecm EndpointCodecMap, options ...ServerOption) *Server {
 	s := &Server{
 		ecm:          ecm,
 		errorEncoder: DefaultErrorEncoder,
+		codecs:       []Codec{DefaultCodec},
+
+		compressionThreshold: DefaultCompressionThreshold,
 	}
 	for _, option := range options {
 		option(s)
 	}
+	s.handler = s.callHandler()
 	return s
 }
 
@@ -54,6 +92,14 @@ func ServerAfter(after ...httpTransport.ServerResponseFunc) ServerOption {
 	return func(s *Server) { s.after = append(s.after, after...) }
 }
 
+// ServerFinalizer is executed at the end of every HTTP request.
+// By default, no finalizer is registered. Response headers and size are
+// available in the context under httpTransport.ContextKeyResponseHeaders
+// and httpTransport.ContextKeyResponseSize.
+func ServerFinalizer(f ...httpTransport.ServerFinalizerFunc) ServerOption {
+	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
+}
+
 // ServerErrorEncoder is used to encode errors to the http.ResponseWriter
 // whenever they're encountered in the processing of a request. Clients can
 // use this to provide custom error formatting and response codes. By default,
@@ -62,9 +108,41 @@ func ServerErrorEncoder(ee httpTransport.ErrorEncoder) ServerOption {
 	return func(s *Server) { s.errorEncoder = ee }
 }
 
+// ServerBatchLimit limits the number of requests in a single batch.
+// Batches exceeding the limit are rejected as a whole with InvalidRequestError.
+// Zero means no limit.
+func ServerBatchLimit(limit int) ServerOption {
+	return func(s *Server) { s.batchLimit = limit }
+}
+
+// ServerBatchParallelism limits the number of batch requests executed
+// concurrently. Zero means every request of the batch gets its own goroutine.
+func ServerBatchParallelism(workers int) ServerOption {
+	return func(s *Server) { s.batchParallel = workers }
+}
+
+// ServerSequentialBatch executes batch requests one by one in request order.
+func ServerSequentialBatch() ServerOption {
+	return ServerBatchParallelism(1)
+}
+
 // ServeHTTP implements http.Handler.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	ctx := r.Context()
+
+	if len(s.finalizer) > 0 {
+		iw := &interceptingWriter{ResponseWriter: w, code: http.StatusOK}
+		defer func() {
+			ctx = context.WithValue(ctx, httpTransport.ContextKeyResponseHeaders, iw.Header())
+			ctx = context.WithValue(ctx, httpTransport.ContextKeyResponseSize, iw.written)
+			for _, f := range s.finalizer {
+				f(ctx, iw.code, r)
+			}
+		}()
+		w = iw
+	}
+
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -72,176 +150,87 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	if s.tracing {
+		ctx = utils.HttpToContext(ctx, r)
+	}
 
 	for _, f := range s.before {
 		ctx = f(ctx, r)
 	}
 
-	bodyData, err := ioutil.ReadAll(r.Body)
-
-	if err != nil {
-		rpcErr := parseError("read body error: " + err.Error())
-		s.errorEncoder(ctx, rpcErr, w)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	ctx = s.authenticate(ctx, r.Header.Get("Authorization"), r.Header.Get(ownerHeader))
 
-	var reqList []Request
+	reqCodec, respCodec := s.negotiate(r.Header.Get("Content-Type"), r.Header.Get("Accept"))
+	ctx = context.WithValue(ctx, codecKey, reqCodec)
 
-	if err = json.Unmarshal(bodyData, &reqList); err != nil {
+	bodyData, err := s.readBody(r.Body, r.Header.Get("Content-Encoding"))
 
-		var req Request
-		if err = json.Unmarshal(bodyData, &req); err != nil {
-			rpcErr := parseError("request body could not be decoded: " + err.Error())
-			s.errorEncoder(ctx, rpcErr, w)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		} else {
-			reqList = append(reqList, req)
-		}
+	if err == errUnsupportedEncoding {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = io.WriteString(w, "415 unsupported content encoding\n")
+		return
 	}
 
-	var wg sync.WaitGroup
-	var respList []Response
+	if err != nil {
+		s.errorEncoder(ctx, err, w)
+		return
+	}
 
 	urlMethod, _ := mux.Vars(r)["method"]
 
-	for _, req := range reqList {
-
-		if urlMethod != "" && req.Method != "" && req.Method != urlMethod {
-
-			if req.ID != nil {
-				respList = append(respList, Response{
-					ID:      req.ID,
-					JSONRPC: Version,
-					Error: &Error{
-						Code:    MethodNotFoundError,
-						Message: fmt.Sprintf("incorrect method: %s != %s", urlMethod, req.Method),
-					},
-				})
-			}
-			continue
-		}
+	if reqList, ok := s.streamableBatch(reqCodec, respCodec, bodyData); ok {
 
-		if urlMethod != "" {
-			req.Method = urlMethod
-		}
-		ecm, ok := s.ecm[req.Method]
-
-		if ! ok {
-			if req.ID != nil {
-				respList = append(respList, Response{
-					ID:      req.ID,
-					JSONRPC: Version,
-					Error: &Error{
-						Code:    MethodNotFoundError,
-						Message: fmt.Sprintf("method %s not found", req.Method),
-					},
-				})
-			}
-			continue
-		}
+		w.Header().Set("Content-Type", respCodec.ContentType())
 
-		ctx = context.WithValue(ctx, reqID, req.ID)
-		reqParams, err := ecm.Decode(ctx, req.Params)
-
-		if err != nil {
-			if req.ID != nil {
-				respList = append(respList, Response{
-					ID:      req.ID,
-					JSONRPC: Version,
-					Error: &Error{
-						Code:    InvalidParamsError,
-						Message: fmt.Sprintf("decode params error: %s", err.Error()),
-					},
-				})
-			}
-			continue
+		for _, f := range s.after {
+			ctx = f(ctx, w)
 		}
 
-		wg.Add(1)
-
-		go func(req Request) {
-
-			defer wg.Done()
-
-			response, err := ecm.Endpoint(ctx, reqParams)
-
-			if err != nil {
+		w.WriteHeader(http.StatusOK)
+		s.serveStream(ctx, urlMethod, reqList, respCodec, w, flusher(w))
+		return
+	}
 
-				if req.ID != nil {
-					respList = append(respList, Response{
-						ID:      req.ID,
-						JSONRPC: Version,
-						Error: &Error{
-							Data:    err,
-							Message: err.Error(),
-							Code:    InternalError,
-						},
-					})
-				}
-				return
-			}
+	batch, respList, err := s.serveBody(ctx, urlMethod, bodyData)
 
-			if req.ID != nil {
-
-				result, err := ecm.Encode(ctx, response)
-
-				if err != nil {
-					if req.ID != nil {
-						respList = append(respList, Response{
-							ID:      req.ID,
-							JSONRPC: Version,
-							Error: &Error{
-								Code:    InternalError,
-								Message: fmt.Sprintf("response encode error: %s", err.Error()),
-							},
-						})
-					}
-					return
-				}
-
-				respList = append(respList, Response{
-					ID:      req.ID,
-					JSONRPC: Version,
-					Result:  result,
-				})
-			}
-		}(req)
+	if err != nil {
+		respList = append(respList, bodyError(err))
 	}
 
-	wg.Wait()
+	data, err := encodeResponses(respCodec, batch, respList)
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", ContentType)
-
-	if len(respList) == 1 {
-		if err := json.NewEncoder(w).Encode(respList[0]); err != nil {
-			log.WithError(err).Error("encode error")
-			return
-		}
-	} else if len(respList) > 1 {
-		if err := json.NewEncoder(w).Encode(respList); err != nil {
-			log.WithError(err).Error("encode error")
-			return
-		}
+	if err != nil {
+		log.WithError(err).Error("encode error")
+		batch, respList = false, []Response{bodyError(internalError("response encode error: " + err.Error()))}
+		data, _ = encodeResponses(respCodec, batch, respList)
 	}
 
+	w.Header().Set("Content-Type", respCodec.ContentType())
+
 	for _, f := range s.after {
 		ctx = f(ctx, w)
 	}
+
+	w.WriteHeader(s.statusCode(batch, respList))
+	_, _ = w.Write(data)
 }
 
 // DefaultErrorEncoder writes the error to the ResponseWriter,
 // as a json-rpc error response, with an InternalError status code.
+// If the error implements httpTransport.StatusCoder, the provided
+// status code will be used instead.
 // The Error() string of the error will be used as the response error message.
 // If the error implements ErrorCoder, the provided code will be set on the
 // response error.
+// If the error implements ErrorDataer, the provided data will be set on the
+// response error.
 // If the error implements Headerer, the given headers will be set.
 func DefaultErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
 
-	w.Header().Set("Content-Type", ContentType)
+	codec := messageCodec(ctx)
+
+	w.Header().Set("Content-Type", codec.ContentType())
 
 	if headerer, ok := err.(httpTransport.Headerer); ok {
 		for k := range headerer.Headers() {
@@ -258,17 +247,30 @@ func DefaultErrorEncoder(ctx context.Context, err error, w http.ResponseWriter)
 		e.Code = sc.ErrorCode()
 	}
 
+	if dataer, ok := err.(ErrorDataer); ok {
+		e.Data = dataer.ErrorData()
+	}
+
+	code := http.StatusInternalServerError
+
+	if sc, ok := err.(httpTransport.StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+
 	reqID, _ := ctx.Value(reqID).(*RequestID)
 
-	w.WriteHeader(http.StatusInternalServerError)
-	_ = json.NewEncoder(w).Encode(Response{
+	data, _ := codec.Marshal(Response{
 		JSONRPC: Version,
 		Error:   &e,
 		ID:      reqID,
 	})
+
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
 }
 
-// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
+// ErrorCoder is checked by DefaultErrorEncoder and by the server for endpoint
+// errors. If an error value implements
 // ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
 // error code when encoding the error.
 //
@@ -277,11 +279,12 @@ type ErrorCoder interface {
 	ErrorCode() int
 }
 
-// interceptingWriter intercepts calls to WriteHeader, so that a finalizer
-// can be given the correct status code.
+// interceptingWriter intercepts calls to WriteHeader and Write, so that a
+// finalizer can be given the correct status code and response size.
 type interceptingWriter struct {
 	http.ResponseWriter
-	code int
+	code    int
+	written int64
 }
 
 // WriteHeader may not be explicitly called, so care must be taken to
@@ -290,3 +293,16 @@ func (w *interceptingWriter) WriteHeader(code int) {
 	w.code = code
 	w.ResponseWriter.WriteHeader(code)
 }
+
+func (w *interceptingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush passes flushes of streamed responses to the underlying writer.
+func (w *interceptingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,110 @@
+package jsonrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+// DefaultCompressionThreshold is the minimal size in bytes of responses
+// compressed by ServeFastHTTP, it matches the threshold of server handlers.
+const DefaultCompressionThreshold = 1024
+
+// ServerCompressionThreshold sets the minimal size in bytes of responses
+// compressed by ServeFastHTTP with the coding accepted by the client.
+// Streamed batches are compressed regardless of the size. Negative size
+// disables compression of responses, compressed requests are accepted anyway.
+// Responses of ServeHTTP are compressed by handlers of the server package.
+func ServerCompressionThreshold(size int) ServerOption {
+	return func(s *Server) { s.compressionThreshold = size }
+}
+
+// errUnsupportedEncoding is the error of request bodies with a content coding
+// other than utils.EncodingBrotli, utils.EncodingGzip or utils.EncodingDeflate.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// readBody reads the request body decompressed according to the value of
+// the Content-Encoding header. The size limit applies to the decompressed
+// body, compressed bodies are limited by utils.DefaultMaxDecompressedSize
+// unless ServerMaxBodySize is set.
+func (s Server) readBody(body io.Reader, contentEncoding string) (data []byte, err error) {
+
+	limit := s.maxBodySize
+
+	if encoding := strings.ToLower(strings.TrimSpace(contentEncoding)); encoding != "" && encoding != "identity" {
+
+		if !utils.SupportedEncoding(encoding) {
+			return nil, errUnsupportedEncoding
+		}
+
+		var reader io.ReadCloser
+
+		if reader, err = utils.NewDecompressor(encoding, body); err != nil {
+			return nil, parseError("read body error: " + err.Error())
+		}
+		defer reader.Close()
+
+		body = reader
+
+		if limit <= 0 {
+			limit = utils.DefaultMaxDecompressedSize
+		}
+	}
+
+	if limit > 0 {
+		body = utils.LimitReader(body, limit)
+	}
+
+	data, err = ioutil.ReadAll(body)
+
+	// the body may be limited by the transport as well
+	if err == utils.ErrTooLarge && limit > 0 {
+		return nil, invalidRequestError(fmt.Sprintf("request body exceeds %d bytes", limit))
+	} else if err == utils.ErrTooLarge {
+		return nil, invalidRequestError("request " + err.Error())
+	} else if err != nil {
+		return nil, parseError("read body error: " + err.Error())
+	}
+	return
+}
+
+// acceptedEncoding returns the content coding of responses negotiated by
+// the Accept-Encoding header, or an empty string if compression is disabled.
+func (s Server) acceptedEncoding(header *fasthttp.RequestHeader) string {
+
+	if s.compressionThreshold < 0 {
+		return ""
+	}
+	return utils.NegotiateEncoding(string(header.Peek("Accept-Encoding")))
+}
+
+// compressResponse compresses the response body of the size above the
+// threshold, the body is sent as is if compression fails.
+func (s Server) compressResponse(rctx *fasthttp.RequestCtx, encoding string, data []byte) []byte {
+
+	if encoding == "" || len(data) < s.compressionThreshold {
+		return data
+	}
+
+	compressed, err := utils.Compress(encoding, data)
+
+	if err != nil {
+		log.WithError(err).Error("compress error")
+		return data
+	}
+
+	setResponseEncoding(rctx, encoding)
+	return compressed
+}
+
+func setResponseEncoding(rctx *fasthttp.RequestCtx, encoding string) {
+
+	rctx.Response.Header.Set("Content-Encoding", encoding)
+	rctx.Response.Header.Add("Vary", "Accept-Encoding")
+}
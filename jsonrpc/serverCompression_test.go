@@ -0,0 +1,231 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/server"
+	"github.com/seniorGolang/gokit/utils"
+)
+
+func TestClientCompression(t *testing.T) {
+
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for _, encoding := range []string{utils.EncodingBrotli, utils.EncodingGzip, utils.EncodingDeflate} {
+
+		client := NewClient(srv.URL, "echo", ClientCompression(encoding, 0))
+
+		if result, err := client.Endpoint()(context.Background(), testParams{A: 5}); err != nil || result == nil {
+			t.Errorf("%s: result %v, error %v", encoding, result, err)
+		}
+	}
+}
+
+func TestServeFastHTTPCompression(t *testing.T) {
+
+	s, _ := newTestServer(t, ServerMaxBodySize(1024))
+
+	compressed, err := utils.Compress(utils.EncodingGzip, []byte(benchRequest))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large, err := utils.Compress(utils.EncodingGzip, []byte(strings.Replace(benchRequest, `"a":1`, `"a":1,"b":"`+strings.Repeat("b", 2048)+`"`, 1)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		code     int
+	}{
+		{"gzip", utils.EncodingGzip, compressed, fasthttp.StatusOK, 0},
+		{"identity", "identity", []byte(benchRequest), fasthttp.StatusOK, 0},
+		{"unsupported", "compress", compressed, fasthttp.StatusUnsupportedMediaType, 0},
+		{"corrupted", utils.EncodingGzip, []byte(benchRequest), fasthttp.StatusOK, ParseError},
+		{"exceeds limit", utils.EncodingGzip, large, fasthttp.StatusOK, InvalidRequestError},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			var req fasthttp.Request
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.Set("Content-Encoding", test.encoding)
+			req.SetRequestURI("/")
+			req.SetBody(test.body)
+
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, benchAddr, nil)
+			s.ServeFastHTTP(&ctx)
+
+			if ctx.Response.StatusCode() != test.status {
+				t.Fatalf("status %d, want %d", ctx.Response.StatusCode(), test.status)
+			}
+
+			if test.status != fasthttp.StatusOK {
+				return
+			}
+
+			if resp := decodeSingle(t, string(ctx.Response.Body())); (test.code == 0) != (resp.Error == nil) || (resp.Error != nil && resp.Error.Code != test.code) {
+				t.Errorf("error %v, want code %d", resp.Error, test.code)
+			}
+		})
+	}
+}
+
+func TestClientDecompressionLimit(t *testing.T) {
+
+	result := strings.Repeat("r", 4096)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		data, _ := utils.Compress(utils.EncodingGzip, []byte(`{"jsonrpc":"2.0","result":"`+result+`","id":1}`))
+
+		w.Header().Set("Content-Encoding", utils.EncodingGzip)
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL, "echo").Endpoint()(context.Background(), testParams{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	limited := NewClient(srv.URL, "echo", func(c *Client) { c.client.MaxResponseBodySize = 1024 })
+
+	if _, err := limited.Endpoint()(context.Background(), testParams{}); err == nil {
+		t.Error("decompressed response exceeding the limit is accepted")
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	// zeros are compressed a thousand times
+	bomb, err := utils.Compress(utils.EncodingGzip, make([]byte, utils.DefaultMaxDecompressedSize+1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handlers := map[string]fasthttp.RequestHandler{
+		"native":  s.ServeFastHTTP,
+		"adapter": server.NewFastHTTPHandler(s),
+	}
+
+	for name, h := range handlers {
+
+		t.Run(name, func(t *testing.T) {
+
+			var req fasthttp.Request
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.Set("Content-Encoding", utils.EncodingGzip)
+			req.SetRequestURI("/")
+			req.SetBody(bomb)
+
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, benchAddr, nil)
+			h(&ctx)
+
+			if resp := decodeSingle(t, string(ctx.Response.Body())); resp.Error == nil || resp.Error.Code != InvalidRequestError {
+				t.Errorf("error %v, want code %d", resp.Error, InvalidRequestError)
+			}
+		})
+	}
+
+	t.Run("net/http", func(t *testing.T) {
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", utils.EncodingGzip)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+
+		if resp := decodeSingle(t, w.Body.String()); resp.Error == nil || resp.Error.Code != InvalidRequestError {
+			t.Errorf("error %v, want code %d", resp.Error, InvalidRequestError)
+		}
+	})
+}
+
+func TestServeFastHTTPResponseCompression(t *testing.T) {
+
+	calls := make([]string, 64)
+
+	for i := range calls {
+		calls[i] = strings.Replace(benchRequest, `"id":1`, `"id":`+strconv.Itoa(i+1), 1)
+	}
+	largeBatch := "[" + strings.Join(calls, ",") + "]"
+
+	plain, _ := newTestServer(t)
+	streamed, _ := newTestServer(t, ServerStreamBatch(true))
+	disabled, _ := newTestServer(t, ServerCompressionThreshold(-1))
+
+	tests := []struct {
+		name       string
+		s          *Server
+		body       string
+		compressed bool
+	}{
+		{"below threshold", plain, benchRequest, false},
+		{"above threshold", plain, largeBatch, true},
+		{"streamed", streamed, benchBatch, true},
+		{"disabled", disabled, largeBatch, false},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			var req fasthttp.Request
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate")
+			req.SetRequestURI("/")
+			req.SetBodyString(test.body)
+
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, benchAddr, nil)
+			test.s.ServeFastHTTP(&ctx)
+
+			body := ctx.Response.Body()
+			encoding := string(ctx.Response.Header.Peek("Content-Encoding"))
+
+			if !test.compressed {
+				if encoding != "" {
+					t.Fatalf("response is compressed with %s", encoding)
+				}
+				return
+			}
+
+			if encoding != utils.EncodingDeflate || string(ctx.Response.Header.Peek("Vary")) != "Accept-Encoding" {
+				t.Fatalf("Content-Encoding %q, Vary %q", encoding, ctx.Response.Header.Peek("Vary"))
+			}
+
+			data, err := utils.Decompress(encoding, body, 0)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if respList := decodeBatch(t, string(data)); len(respList) != strings.Count(test.body, "jsonrpc") {
+				t.Errorf("%d responses of %s", len(respList), data)
+			}
+		})
+	}
+}
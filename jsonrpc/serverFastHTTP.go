@@ -0,0 +1,180 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+// FastRequestFunc may take information from the fasthttp request and put it
+// into the context. FastRequestFuncs are executed before the request is decoded.
+type FastRequestFunc func(context.Context, *fasthttp.RequestCtx) context.Context
+
+// FastResponseFunc may take information from the context and use it to
+// manipulate the fasthttp response. FastResponseFuncs are executed after
+// the response is written.
+type FastResponseFunc func(context.Context, *fasthttp.RequestCtx) context.Context
+
+// ServerFastBefore functions are executed on the fasthttp request before the
+// request is decoded, when the server is used as fasthttp.RequestHandler.
+func ServerFastBefore(before ...FastRequestFunc) ServerOption {
+	return func(s *Server) { s.fastBefore = append(s.fastBefore, before...) }
+}
+
+// ServerFastAfter functions are executed on the fasthttp request after the
+// response is written, when the server is used as fasthttp.RequestHandler.
+func ServerFastAfter(after ...FastResponseFunc) ServerOption {
+	return func(s *Server) { s.fastAfter = append(s.fastAfter, after...) }
+}
+
+// ServeFastHTTP implements fasthttp.RequestHandler, so the server can be used
+// directly by fasthttp.Server without the net/http adapter. The request body
+// is used in place and responses are written straight to the fasthttp buffer.
+// The method may be taken from the "method" user value set by a router.
+func (s Server) ServeFastHTTP(rctx *fasthttp.RequestCtx) {
+
+	if !rctx.IsPost() {
+		rctx.Error("405 must POST\n", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	root := &requestContext{Context: rctx}
+	var ctx context.Context = root
+
+	if s.tracing {
+		ctx = fastHeadersToContext(ctx, &rctx.Request.Header)
+	}
+
+	for _, f := range s.fastBefore {
+		ctx = f(ctx, rctx)
+	}
+
+	ctx = s.authenticate(ctx, string(rctx.Request.Header.Peek("Authorization")), string(rctx.Request.Header.Peek(ownerHeader)))
+
+	reqCodec, respCodec := s.negotiate(string(rctx.Request.Header.ContentType()), string(rctx.Request.Header.Peek("Accept")))
+	ctx = context.WithValue(ctx, codecKey, reqCodec)
+
+	urlMethod, _ := rctx.UserValue("method").(string)
+	encoding := s.acceptedEncoding(&rctx.Request.Header)
+
+	var err error
+	body := rctx.PostBody()
+
+	if contentEncoding := rctx.Request.Header.Peek("Content-Encoding"); len(contentEncoding) != 0 {
+		if body, err = s.readBody(bytes.NewReader(body), string(contentEncoding)); err == errUnsupportedEncoding {
+			rctx.Error("415 unsupported content encoding\n", fasthttp.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	if reqList, ok := s.streamableBatch(reqCodec, respCodec, body); err == nil && ok {
+
+		rctx.SetContentType(respCodec.ContentType())
+		rctx.SetStatusCode(fasthttp.StatusOK)
+
+		// headers are sent before the body is streamed
+		if encoding != "" {
+			setResponseEncoding(rctx, encoding)
+		}
+
+		// the response is written after the handler returns,
+		// so after functions see the response before the body
+		for _, f := range s.fastAfter {
+			ctx = f(ctx, rctx)
+		}
+
+		// the batch is served after the handler returns and the RequestCtx
+		// may be reused, so the context must not refer to it
+		root.detach(rctx)
+
+		rctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+
+			var out io.Writer = w
+			flush := func() { _ = w.Flush() }
+
+			if encoding != "" {
+				compressor, _ := utils.NewCompressor(encoding, w)
+				defer compressor.Close()
+				out, flush = compressor, func() { _ = compressor.Flush(); _ = w.Flush() }
+			}
+			s.serveStream(ctx, urlMethod, reqList, respCodec, out, flush)
+		})
+		return
+	}
+
+	var batch bool
+	var respList []Response
+
+	if err == nil {
+		batch, respList, err = s.serveBody(ctx, urlMethod, body)
+	}
+
+	if err != nil {
+		respList = append(respList, bodyError(err))
+	}
+
+	data, err := encodeResponses(respCodec, batch, respList)
+
+	if err != nil {
+		log.WithError(err).Error("encode error")
+		batch, respList = false, []Response{bodyError(internalError("response encode error: " + err.Error()))}
+		data, _ = encodeResponses(respCodec, batch, respList)
+	}
+
+	rctx.SetContentType(respCodec.ContentType())
+	rctx.SetStatusCode(s.statusCode(batch, respList))
+	rctx.SetBody(s.compressResponse(rctx, encoding, data))
+
+	for _, f := range s.fastAfter {
+		ctx = f(ctx, rctx)
+	}
+}
+
+// requestContext is the root context of calls served by ServeFastHTTP,
+// it refers to the fasthttp.RequestCtx until it is detached.
+type requestContext struct {
+	context.Context
+	values map[string]interface{}
+}
+
+func (c *requestContext) Value(key interface{}) interface{} {
+
+	if c.values == nil {
+		return c.Context.Value(key)
+	}
+
+	name, _ := key.(string)
+	return c.values[name]
+}
+
+// detach copies user values of the RequestCtx and replaces it by the
+// background context, values added over the root context are kept.
+func (c *requestContext) detach(rctx *fasthttp.RequestCtx) {
+
+	c.values = make(map[string]interface{})
+
+	rctx.VisitUserValues(func(key []byte, value interface{}) {
+		c.values[string(key)] = value
+	})
+	c.Context = context.Background()
+}
+
+// fastHeadersToContext stores request headers in the context the same way as
+// utils.HttpToContext does for net/http requests.
+func fastHeadersToContext(ctx context.Context, header *fasthttp.RequestHeader) context.Context {
+
+	headers := make(map[string]interface{})
+
+	header.VisitAll(func(key, value []byte) {
+		if len(value) != 0 {
+			headers[strings.ToLower(string(key))] = string(value)
+		}
+	})
+	return utils.HeadersToContext(ctx, headers)
+}
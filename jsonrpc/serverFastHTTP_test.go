@@ -0,0 +1,134 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/server"
+)
+
+const (
+	benchRequest = `{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":1}`
+	benchBatch   = `[{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":1},{"jsonrpc":"2.0","method":"echo","params":{"a":2},"id":2}]`
+)
+
+func TestServeFastHTTP(t *testing.T) {
+
+	s, _ := newTestServer(t)
+
+	for _, body := range []string{benchRequest, benchBatch} {
+
+		native := serveFast(s.ServeFastHTTP, body)
+		adapted := serveFast(server.NewFastHTTPHandler(s), body)
+
+		if native.StatusCode() != fasthttp.StatusOK || string(native.Body()) != string(adapted.Body()) {
+			t.Errorf("native response %d %s, adapter response %s", native.StatusCode(), native.Body(), adapted.Body())
+		}
+	}
+}
+
+func BenchmarkServeFastHTTP(b *testing.B) {
+	benchmarkFastHandler(b, func(s *Server) fasthttp.RequestHandler { return s.ServeFastHTTP }, benchRequest)
+}
+
+func BenchmarkFastHTTPAdapter(b *testing.B) {
+	benchmarkFastHandler(b, func(s *Server) fasthttp.RequestHandler { return server.NewFastHTTPHandler(s) }, benchRequest)
+}
+
+func BenchmarkServeFastHTTPBatch(b *testing.B) {
+	benchmarkFastHandler(b, func(s *Server) fasthttp.RequestHandler { return s.ServeFastHTTP }, benchBatch)
+}
+
+func BenchmarkFastHTTPAdapterBatch(b *testing.B) {
+	benchmarkFastHandler(b, func(s *Server) fasthttp.RequestHandler { return server.NewFastHTTPHandler(s) }, benchBatch)
+}
+
+func benchmarkFastHandler(b *testing.B, handler func(s *Server) fasthttp.RequestHandler, body string) {
+
+	svc := new(testService)
+	ecm, err := MakeEndpointCodecMap("", svc)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	h := handler(NewServer(ecm))
+
+	var req fasthttp.Request
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/")
+	req.SetBodyString(body)
+
+	var ctx fasthttp.RequestCtx
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ctx.Init(&req, benchAddr, nil)
+		h(&ctx)
+	}
+}
+
+var benchAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+
+// serveFast executes the handler with the request body and returns the response.
+func serveFast(h fasthttp.RequestHandler, body string) *fasthttp.Response {
+
+	var req fasthttp.Request
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/")
+	req.SetBodyString(body)
+
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&req, benchAddr, nil)
+	h(&ctx)
+
+	resp := new(fasthttp.Response)
+	ctx.Response.CopyTo(resp)
+	return resp
+}
+
+func TestServeFastHTTPStreamContext(t *testing.T) {
+
+	values := make(chan interface{}, 2)
+
+	s, _ := newTestServer(t,
+		ServerStreamBatch(false),
+		ServerFastBefore(func(ctx context.Context, rctx *fasthttp.RequestCtx) context.Context {
+			rctx.SetUserValue("user", "value")
+			return ctx
+		}),
+		ServerInterceptors(func(next CallHandler) CallHandler {
+			return func(ctx context.Context, call *Call) (interface{}, error) {
+				values <- ctx.Value("user")
+				return next(ctx, call)
+			}
+		}),
+	)
+
+	var req fasthttp.Request
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/")
+	req.SetBodyString(benchBatch)
+
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&req, benchAddr, nil)
+	s.ServeFastHTTP(&ctx)
+
+	// the RequestCtx is reused before the batch is streamed
+	ctx.SetUserValue("user", "next request")
+
+	if respList := decodeBatch(t, string(ctx.Response.Body())); len(respList) != 2 {
+		t.Fatalf("unexpected responses %v", respList)
+	}
+
+	for i := 0; i < 2; i++ {
+		if value := <-values; value != "value" {
+			t.Errorf("call %d: user value %v", i, value)
+		}
+	}
+}
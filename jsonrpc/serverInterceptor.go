@@ -0,0 +1,47 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Call is a single JSON RPC call passed through interceptors of the server.
+// Params are decoded by the codec of the method, ID is nil for notifications.
+type Call struct {
+	Method string
+	ID     *RequestID
+	Params interface{}
+
+	endpoint endpoint.Endpoint
+}
+
+// CallHandler executes the call and returns the result of the endpoint,
+// which is not encoded yet.
+type CallHandler func(ctx context.Context, call *Call) (result interface{}, err error)
+
+// Interceptor wraps execution of calls, e.g. to check permissions of methods,
+// log calls or collect metrics. It may change the call before passing it to
+// next or return without calling next at all, the returned error is encoded
+// as the error of the call.
+type Interceptor func(next CallHandler) CallHandler
+
+// ServerInterceptors adds interceptors of calls. Interceptors are invoked
+// after params are decoded, the first one is the outermost. Calls to
+// unknown methods and calls with invalid params don't reach interceptors.
+func ServerInterceptors(interceptors ...Interceptor) ServerOption {
+	return func(s *Server) { s.interceptors = append(s.interceptors, interceptors...) }
+}
+
+// callHandler chains interceptors of the server around the endpoint of the call.
+func (s Server) callHandler() (handler CallHandler) {
+
+	handler = func(ctx context.Context, call *Call) (interface{}, error) {
+		return call.endpoint(ctx, call.Params)
+	}
+
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		handler = s.interceptors[i](handler)
+	}
+	return
+}
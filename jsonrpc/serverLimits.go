@@ -0,0 +1,328 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	strictParamsKey contextKey = "strictParams"
+
+	// defaultMaxDepth protects the scanner stack when only the string
+	// length is limited, it matches the limit of encoding/json.
+	defaultMaxDepth = 10000
+)
+
+// ServerMaxBodySize limits the size of the request body in bytes, compressed
+// bodies are limited after decompression. Zero means no limit, except for
+// compressed bodies limited by utils.DefaultMaxDecompressedSize.
+func ServerMaxBodySize(size int64) ServerOption {
+	return func(s *Server) { s.maxBodySize = size }
+}
+
+// ServerMaxDepth limits nesting of objects and arrays in the request body,
+// the batch array counts as a level. Zero means no limit.
+func ServerMaxDepth(depth int) ServerOption {
+	return func(s *Server) { s.maxDepth = depth }
+}
+
+// ServerMaxStringLength limits the length in bytes of JSON strings in the
+// request body, object keys included. Zero means no limit.
+func ServerMaxStringLength(length int) ServerOption {
+	return func(s *Server) { s.maxStringLength = length }
+}
+
+// ServerDisallowUnknownFields makes decoders created by MakeParamsDecoder and
+// MakeEndpointCodecMap reject params with fields unknown to the request type.
+// Custom decoders may check the mode with StrictParams.
+func ServerDisallowUnknownFields() ServerOption {
+	return func(s *Server) { s.strictParams = true }
+}
+
+// StrictParams reports whether unknown fields of params must be rejected.
+func StrictParams(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictParamsKey).(bool)
+	return strict
+}
+
+// checkBody checks the request body against size, depth and string limits.
+// Depth and strings are checked by a single scan, which also validates the
+// syntax, so it runs only when these limits are set.
+func (s Server) checkBody(data []byte) error {
+
+	if s.maxBodySize > 0 && int64(len(data)) > s.maxBodySize {
+		return invalidRequestError(fmt.Sprintf("request body exceeds %d bytes", s.maxBodySize))
+	}
+
+	if s.maxDepth <= 0 && s.maxStringLength <= 0 {
+		return nil
+	}
+
+	scanner := jsonScanner{
+		data:      data,
+		maxDepth:  s.maxDepth,
+		maxString: s.maxStringLength,
+	}
+
+	if scanner.maxDepth <= 0 {
+		scanner.maxDepth = defaultMaxDepth
+	}
+	return scanner.scan()
+}
+
+// binaryChecker is implemented by binary codecs scanning messages for
+// nesting and string limits without decoding them.
+type binaryChecker interface {
+	check(data []byte, maxDepth, maxString int) error
+}
+
+// checkBinary checks the binary request body against depth and string
+// limits before it is decoded, so the limits protect decoders as well.
+func (s Server) checkBinary(codec Codec, data []byte) error {
+
+	checker, ok := codec.(binaryChecker)
+
+	if !ok || (s.maxDepth <= 0 && s.maxStringLength <= 0) {
+		return nil
+	}
+
+	err := checker.check(data, s.maxDepth, s.maxStringLength)
+
+	if _, exceeded := err.(limitError); exceeded {
+		return invalidRequestError(err.Error())
+	} else if err != nil {
+		return parseError("request body could not be decoded: " + err.Error())
+	}
+	return nil
+}
+
+// jsonScanner validates JSON syntax and limits without decoding values.
+type jsonScanner struct {
+	data      []byte
+	pos       int
+	maxDepth  int
+	maxString int
+}
+
+func (s *jsonScanner) scan() (err error) {
+
+	if err = s.value(0); err != nil {
+		return
+	}
+
+	if s.skipSpace(); s.pos != len(s.data) {
+		return s.syntaxError("unexpected data after top-level value")
+	}
+	return
+}
+
+func (s *jsonScanner) value(depth int) error {
+
+	s.skipSpace()
+
+	if s.pos >= len(s.data) {
+		return s.syntaxError("unexpected end of JSON input")
+	}
+
+	switch c := s.data[s.pos]; {
+
+	case c == '{' || c == '[':
+		return s.composite(depth + 1)
+
+	case c == '"':
+		return s.string()
+
+	case c == 't':
+		return s.literal("true")
+
+	case c == 'f':
+		return s.literal("false")
+
+	case c == 'n':
+		return s.literal("null")
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		return s.number()
+	}
+	return s.syntaxError(fmt.Sprintf("invalid character %q looking for beginning of value", s.data[s.pos]))
+}
+
+// composite scans an object or an array.
+func (s *jsonScanner) composite(depth int) (err error) {
+
+	if s.maxDepth > 0 && depth > s.maxDepth {
+		return invalidRequestError(fmt.Sprintf("JSON nesting exceeds %d levels", s.maxDepth))
+	}
+
+	object := s.data[s.pos] == '{'
+	closing := byte(']')
+
+	if object {
+		closing = '}'
+	}
+
+	s.pos++
+
+	if s.skipSpace(); s.pos < len(s.data) && s.data[s.pos] == closing {
+		s.pos++
+		return nil
+	}
+
+	for {
+
+		if object {
+			if s.skipSpace(); s.pos >= len(s.data) || s.data[s.pos] != '"' {
+				return s.syntaxError("object key must be a string")
+			}
+			if err = s.string(); err != nil {
+				return
+			}
+			if s.skipSpace(); s.pos >= len(s.data) || s.data[s.pos] != ':' {
+				return s.syntaxError("expected colon after object key")
+			}
+			s.pos++
+		}
+
+		if err = s.value(depth); err != nil {
+			return
+		}
+
+		if s.skipSpace(); s.pos >= len(s.data) {
+			return s.syntaxError("unexpected end of JSON input")
+		}
+
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+		case closing:
+			s.pos++
+			return nil
+		default:
+			return s.syntaxError(fmt.Sprintf("invalid character %q after element", s.data[s.pos]))
+		}
+	}
+}
+
+func (s *jsonScanner) string() error {
+
+	start := s.pos
+	s.pos++
+
+	for s.pos < len(s.data) {
+
+		switch c := s.data[s.pos]; {
+
+		case c == '"':
+			s.pos++
+			// quotes are not counted
+			if s.maxString > 0 && s.pos-start-2 > s.maxString {
+				return invalidRequestError(fmt.Sprintf("JSON string exceeds %d bytes", s.maxString))
+			}
+			return nil
+
+		case c == '\\':
+			if s.pos+1 >= len(s.data) {
+				return s.syntaxError("unexpected end of JSON input")
+			}
+			switch s.data[s.pos+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				s.pos += 2
+			case 'u':
+				if s.pos+6 > len(s.data) || !isHex(s.data[s.pos+2:s.pos+6]) {
+					return s.syntaxError("invalid unicode escape")
+				}
+				s.pos += 6
+			default:
+				return s.syntaxError("invalid escape")
+			}
+
+		case c < 0x20:
+			return s.syntaxError("invalid control character in string")
+
+		default:
+			s.pos++
+		}
+	}
+	return s.syntaxError("unexpected end of JSON input")
+}
+
+func (s *jsonScanner) number() error {
+
+	start := s.pos
+
+	if s.data[s.pos] == '-' {
+		s.pos++
+	}
+
+	if s.pos < len(s.data) && s.data[s.pos] == '0' {
+		s.pos++
+	} else if !s.digits() {
+		return s.syntaxError("invalid number")
+	}
+
+	if s.pos < len(s.data) && s.data[s.pos] == '.' {
+		if s.pos++; !s.digits() {
+			return s.syntaxError("invalid number")
+		}
+	}
+
+	if s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		if s.pos++; s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+			s.pos++
+		}
+		if !s.digits() {
+			return s.syntaxError("invalid number")
+		}
+	}
+
+	if s.maxString > 0 && s.pos-start > s.maxString {
+		return invalidRequestError(fmt.Sprintf("JSON number exceeds %d bytes", s.maxString))
+	}
+	return nil
+}
+
+func (s *jsonScanner) digits() bool {
+
+	start := s.pos
+
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	return s.pos > start
+}
+
+func (s *jsonScanner) literal(literal string) error {
+
+	if len(s.data)-s.pos < len(literal) || string(s.data[s.pos:s.pos+len(literal)]) != literal {
+		return s.syntaxError("invalid literal")
+	}
+
+	s.pos += len(literal)
+	return nil
+}
+
+func (s *jsonScanner) skipSpace() {
+
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) syntaxError(message string) error {
+	return parseError(fmt.Sprintf("request body could not be decoded: %s at offset %d", message, s.pos))
+}
+
+func isHex(data []byte) bool {
+
+	for _, c := range data {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+const (
+	// concurrencyRetryAfter is the retry hint for calls exceeding concurrency
+	// limits, since the end of running calls can't be predicted.
+	concurrencyRetryAfter = time.Second
+
+	// sweepInterval is the period of removing idle buckets of owners.
+	sweepInterval = time.Minute
+)
+
+// RateLimitData is the data of RateLimitError errors.
+type RateLimitData struct {
+	// RetryAfter is the number of seconds to wait before the next call.
+	RetryAfter int `json:"retryAfter"`
+}
+
+// ServerRateLimit limits calls of the method to rate calls per second
+// with bursts of up to burst calls. A non-positive rate means no limit.
+func ServerRateLimit(method string, rate float64, burst int) ServerOption {
+	return func(s *Server) {
+		if rate > 0 {
+			s.methodLimits(method).rate = newTokenBucket(rate, burst)
+		}
+	}
+}
+
+// ServerOwnerRateLimit limits calls of the method by each owner returned
+// by utils.GetOwnerId to rate calls per second with bursts of up to burst
+// calls. The owner is the subject of the token verified by the authenticator,
+// or the X-User-Id header without authenticator. Anonymous calls share
+// a single limit. A non-positive rate means no limit.
+func ServerOwnerRateLimit(method string, rate float64, burst int) ServerOption {
+	return func(s *Server) {
+		if rate > 0 {
+			s.methodLimits(method).ownerRate = newOwnerBuckets(rate, burst)
+		}
+	}
+}
+
+// ServerConcurrencyLimit limits the number of concurrent calls of the method.
+// A non-positive max means no limit.
+func ServerConcurrencyLimit(method string, max int) ServerOption {
+	return func(s *Server) {
+		if max > 0 {
+			s.methodLimits(method).concurrency = newConcurrencyLimit(max)
+		}
+	}
+}
+
+// ServerOwnerConcurrencyLimit limits the number of concurrent calls of the
+// method by each owner returned by utils.GetOwnerId, owners are taken as
+// by ServerOwnerRateLimit. Anonymous calls share a single limit.
+// A non-positive max means no limit.
+func ServerOwnerConcurrencyLimit(method string, max int) ServerOption {
+	return func(s *Server) {
+		if max > 0 {
+			s.methodLimits(method).ownerConcurrency = newConcurrencyLimit(max)
+		}
+	}
+}
+
+// limits are rate and concurrency limits of a method.
+type limits struct {
+	rate             *tokenBucket
+	ownerRate        *ownerBuckets
+	concurrency      *concurrencyLimit
+	ownerConcurrency *concurrencyLimit
+}
+
+func (s *Server) methodLimits(method string) *limits {
+
+	if s.limits == nil {
+		s.limits = make(map[string]*limits)
+	}
+
+	if s.limits[method] == nil {
+		s.limits[method] = new(limits)
+	}
+	return s.limits[method]
+}
+
+// acquire checks limits of the method for the call. The returned release
+// function must be called when the call is done.
+func (s Server) acquire(ctx context.Context, method string) (release func(), err error) {
+
+	release = func() {}
+
+	l, found := s.limits[method]
+
+	if !found {
+		return
+	}
+
+	owner := utils.GetOwnerId(ctx)
+	now := time.Now()
+
+	if l.rate != nil {
+		if retryAfter, ok := l.rate.take(now); !ok {
+			return release, rateLimitError(method, "rate", retryAfter)
+		}
+	}
+
+	if l.ownerRate != nil {
+		if retryAfter, ok := l.ownerRate.take(owner, now); !ok {
+			return release, rateLimitError(method, "owner rate", retryAfter)
+		}
+	}
+
+	if l.concurrency != nil {
+		if !l.concurrency.acquire("") {
+			return release, rateLimitError(method, "concurrency", concurrencyRetryAfter)
+		}
+	}
+
+	if l.ownerConcurrency != nil {
+		if !l.ownerConcurrency.acquire(owner) {
+			if l.concurrency != nil {
+				l.concurrency.release("")
+			}
+			return release, rateLimitError(method, "owner concurrency", concurrencyRetryAfter)
+		}
+	}
+
+	return func() {
+		if l.concurrency != nil {
+			l.concurrency.release("")
+		}
+		if l.ownerConcurrency != nil {
+			l.ownerConcurrency.release(owner)
+		}
+	}, nil
+}
+
+// tokenBucket is a token bucket refilled with rate tokens per second.
+type tokenBucket struct {
+	lock  sync.Mutex
+	rate  float64
+	burst float64
+	state bucketState
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:  rate,
+		burst: math.Max(float64(burst), 1),
+		state: bucketState{tokens: math.Max(float64(burst), 1)},
+	}
+}
+
+func (b *tokenBucket) take(now time.Time) (retryAfter time.Duration, ok bool) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state.take(b.rate, b.burst, now)
+}
+
+// take refills the bucket and takes a token if available, otherwise returns
+// the time until the next token.
+func (b *bucketState) take(rate, burst float64, now time.Time) (retryAfter time.Duration, ok bool) {
+
+	if !b.last.IsZero() {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+}
+
+// ownerBuckets are token buckets of owners. Buckets refilled to the full
+// burst are equal to new ones, so they are removed periodically.
+type ownerBuckets struct {
+	lock      sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+}
+
+func newOwnerBuckets(rate float64, burst int) *ownerBuckets {
+	return &ownerBuckets{
+		rate:    rate,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*bucketState),
+	}
+}
+
+func (b *ownerBuckets) take(owner string, now time.Time) (retryAfter time.Duration, ok bool) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if now.Sub(b.lastSweep) > sweepInterval {
+		b.sweep(now)
+	}
+
+	bucket, found := b.buckets[owner]
+
+	if !found {
+		bucket = &bucketState{tokens: b.burst}
+		b.buckets[owner] = bucket
+	}
+	return bucket.take(b.rate, b.burst, now)
+}
+
+func (b *ownerBuckets) sweep(now time.Time) {
+
+	b.lastSweep = now
+
+	for owner, bucket := range b.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*b.rate >= b.burst {
+			delete(b.buckets, owner)
+		}
+	}
+}
+
+// concurrencyLimit counts running calls by key.
+type concurrencyLimit struct {
+	lock   sync.Mutex
+	max    int
+	active map[string]int
+}
+
+func newConcurrencyLimit(max int) *concurrencyLimit {
+	return &concurrencyLimit{
+		max:    max,
+		active: make(map[string]int),
+	}
+}
+
+func (l *concurrencyLimit) acquire(key string) bool {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.active[key] >= l.max {
+		return false
+	}
+
+	l.active[key]++
+	return true
+}
+
+func (l *concurrencyLimit) release(key string) {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.active[key]--; l.active[key] <= 0 {
+		delete(l.active, key)
+	}
+}
+
+// rateLimitError builds the error of the exceeded limit of the method,
+// retryAfter is rounded up to seconds.
+func rateLimitError(method, limit string, retryAfter time.Duration) error {
+
+	return Error{
+		Code:    RateLimitError,
+		Message: fmt.Sprintf("%s limit of %s exceeded", limit, method),
+		Data:    RateLimitData{RetryAfter: int(math.Ceil(retryAfter.Seconds()))},
+	}
+}
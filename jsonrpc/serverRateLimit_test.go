@@ -0,0 +1,150 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// callAs calls echo on behalf of the owner over net/http or the native
+// fasthttp handler and returns the error code of the response.
+func callAs(t *testing.T, s *Server, native bool, owner, token string, delay int) int {
+
+	body := `{"jsonrpc":"2.0","method":"echo","params":{"a":1,"delay":` + strconv.Itoa(delay) + `},"id":1}`
+	header := map[string]string{ownerHeader: owner, "Authorization": "Bearer " + token}
+
+	var respBody string
+
+	if native {
+
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("/")
+		req.SetBodyString(body)
+
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		var ctx fasthttp.RequestCtx
+		ctx.Init(&req, benchAddr, nil)
+		s.ServeFastHTTP(&ctx)
+		respBody = string(ctx.Response.Body())
+	} else {
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		respBody = w.Body.String()
+	}
+
+	if resp := decodeSingle(t, respBody); resp.Error != nil {
+		return resp.Error.Code
+	}
+	return 0
+}
+
+func TestServerRateLimit(t *testing.T) {
+
+	s, _ := newTestServer(t, ServerRateLimit("echo", 0.1, 2))
+
+	for i, want := range []int{0, 0, RateLimitError} {
+		if code := callAs(t, s, false, strconv.Itoa(i), "", 0); code != want {
+			t.Errorf("call %d: code %d, want %d", i, code, want)
+		}
+	}
+}
+
+func TestServerOwnerRateLimit(t *testing.T) {
+
+	for _, native := range []bool{false, true} {
+
+		t.Run("native "+strconv.FormatBool(native), func(t *testing.T) {
+
+			s, _ := newTestServer(t, ServerOwnerRateLimit("echo", 0.1, 1))
+
+			calls := []struct {
+				owner string
+				code  int
+			}{
+				{"alice", 0},
+				{"alice", RateLimitError},
+				{"bob", 0},
+				{"", 0},
+				{"", RateLimitError},
+			}
+
+			for i, call := range calls {
+				if code := callAs(t, s, native, call.owner, "", 0); code != call.code {
+					t.Errorf("call %d by %q: code %d, want %d", i, call.owner, code, call.code)
+				}
+			}
+		})
+	}
+}
+
+func TestServerOwnerRateLimitAuthenticated(t *testing.T) {
+
+	s, _ := newTestServer(t,
+		ServerAuthenticator(StaticTokens(map[string]Claims{"token": {Subject: "alice"}})),
+		ServerPublicMethods("echo"),
+		ServerOwnerRateLimit("echo", 0.1, 1),
+	)
+
+	// the owner header is not trusted once the authenticator is set
+	if code := callAs(t, s, false, "bob", "token", 0); code != 0 {
+		t.Fatalf("code %d, want success", code)
+	}
+
+	if code := callAs(t, s, false, "bob", "", 0); code != 0 {
+		t.Fatalf("anonymous call: code %d, want success", code)
+	}
+
+	if code := callAs(t, s, false, "carol", "", 0); code != RateLimitError {
+		t.Errorf("anonymous call with other owner header: code %d, want %d", code, RateLimitError)
+	}
+}
+
+func TestServerOwnerConcurrencyLimit(t *testing.T) {
+
+	s, svc := newTestServer(t, ServerOwnerConcurrencyLimit("echo", 1))
+
+	owners := []string{"alice", "alice", "bob"}
+	codes := make([]int, len(owners))
+
+	var wg sync.WaitGroup
+
+	for i, owner := range owners {
+
+		wg.Add(1)
+
+		go func(i int, owner string) {
+			defer wg.Done()
+			codes[i] = callAs(t, s, false, owner, "", 100)
+		}(i, owner)
+	}
+	wg.Wait()
+
+	if limited := (codes[0] == RateLimitError) != (codes[1] == RateLimitError); !limited || codes[2] != 0 {
+		t.Errorf("codes %v, want one call of alice limited", codes)
+	}
+
+	if svc.peak != 2 {
+		t.Errorf("peak of %d concurrent calls, want 2", svc.peak)
+	}
+
+	// released limits allow next calls
+	if code := callAs(t, s, false, "alice", "", 0); code != 0 {
+		t.Errorf("code %d after calls are done", code)
+	}
+}
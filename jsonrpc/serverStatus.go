@@ -0,0 +1,50 @@
+package jsonrpc
+
+import (
+	"net/http"
+)
+
+// ServerErrorStatus makes the server map the JSON RPC error of a single
+// response to the HTTP status code with mapper, e.g. ErrorStatus.
+// By default, responses are sent with 200 OK as the specification suggests,
+// errors are reported by the response body only. Batches are always sent
+// with 200 OK, since they may contain both results and errors.
+func ServerErrorStatus(mapper func(code int) int) ServerOption {
+	return func(s *Server) { s.errorStatus = mapper }
+}
+
+// ErrorStatus maps JSON RPC error codes to HTTP status codes:
+// request errors are mapped to 400 Bad Request, authentication errors to
+// 401 Unauthorized, permission errors to 403 Forbidden, unknown methods to
+// 404 Not Found, exceeded limits to 429 Too Many Requests and other errors
+// to 500 Internal Server Error.
+func ErrorStatus(code int) int {
+
+	switch code {
+
+	case ParseError, InvalidRequestError, InvalidParamsError:
+		return http.StatusBadRequest
+
+	case UnauthorizedError:
+		return http.StatusUnauthorized
+
+	case ForbiddenError:
+		return http.StatusForbidden
+
+	case MethodNotFoundError:
+		return http.StatusNotFound
+
+	case RateLimitError:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// statusCode returns the HTTP status code of responses.
+func (s Server) statusCode(batch bool, respList []Response) int {
+
+	if s.errorStatus == nil || batch || len(respList) != 1 || respList[0].Error == nil {
+		return http.StatusOK
+	}
+	return s.errorStatus(respList[0].Error.Code)
+}
@@ -0,0 +1,159 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ServerStreamBatch makes the server write responses of batches to the
+// client as soon as they are ready instead of buffering the whole batch,
+// so batches with large results don't hold all of them in memory.
+// If ordered, responses are written in the order of requests, so a ready
+// response waits for slower ones before it. Streamed batches are always
+// sent with 200 OK, batches rejected as a whole are not streamed.
+func ServerStreamBatch(ordered bool) ServerOption {
+	return func(s *Server) {
+		s.streamBatch = true
+		s.streamOrdered = ordered
+	}
+}
+
+// streamableBatch returns requests of the body if it is a valid batch
+// to be streamed, other bodies are served by serveBody. Responses encoded
+// by binary codecs are not streamed, since the stream is framed as JSON.
+func (s Server) streamableBatch(codec, respCodec Codec, data []byte) (reqList []json.RawMessage, ok bool) {
+
+	if _, binary := respCodec.(BinaryCodec); binary {
+		return nil, false
+	}
+
+	if !s.streamBatch || firstByte(data) != '[' || s.checkBody(data) != nil {
+		return nil, false
+	}
+
+	reqList, errResp, err := s.decodeBatch(codec, data)
+	return reqList, err == nil && errResp == nil
+}
+
+// serveStream executes the batch writing responses encoded by the codec to w
+// as they are ready, flush is called after each response. Execution of the
+// batch is canceled once writing fails.
+func (s Server) serveStream(ctx context.Context, urlMethod string, reqList []json.RawMessage, codec Codec, w io.Writer, flush func()) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream := &batchStream{
+		w:       w,
+		codec:   codec,
+		flush:   flush,
+		cancel:  cancel,
+		ordered: s.streamOrdered,
+		pending: make(map[int]*Response),
+	}
+
+	s.executeBatch(ctx, urlMethod, reqList, stream.emit)
+	stream.close()
+}
+
+// flusher returns the function flushing the writer, if it supports flushing.
+func flusher(w http.ResponseWriter) func() {
+
+	if f, ok := w.(http.Flusher); ok {
+		return f.Flush
+	}
+	return func() {}
+}
+
+// batchStream writes responses of the batch as elements of a JSON array.
+type batchStream struct {
+	lock sync.Mutex
+
+	w      io.Writer
+	codec  Codec
+	flush  func()
+	cancel func()
+	err    error
+
+	written int
+
+	ordered bool
+	next    int
+	pending map[int]*Response
+}
+
+// emit writes the response of the i-th request of the batch. In ordered mode
+// responses are held until responses of all previous requests are written.
+func (b *batchStream) emit(i int, resp *Response) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.ordered {
+		b.write(resp)
+		return
+	}
+
+	b.pending[i] = resp
+
+	for {
+		resp, found := b.pending[b.next]
+		if !found {
+			return
+		}
+		delete(b.pending, b.next)
+		b.next++
+		b.write(resp)
+	}
+}
+
+func (b *batchStream) write(resp *Response) {
+
+	if resp == nil || b.err != nil {
+		return
+	}
+
+	data, err := b.codec.Marshal(resp)
+
+	if err != nil {
+		log.WithError(err).Error("encode error")
+		return
+	}
+
+	separator := ","
+
+	if b.written == 0 {
+		separator = "["
+	}
+
+	if _, b.err = io.WriteString(b.w, separator); b.err == nil {
+		_, b.err = b.w.Write(data)
+	}
+
+	if b.err != nil {
+		log.WithError(b.err).Debug("stream write error")
+		b.cancel()
+		return
+	}
+
+	b.written++
+	b.flush()
+}
+
+// close terminates the array, batches of notifications have no response.
+func (b *batchStream) close() {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.written == 0 || b.err != nil {
+		return
+	}
+
+	if _, b.err = io.WriteString(b.w, "]"); b.err == nil {
+		b.flush()
+	}
+}
@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/seniorGolang/gokit/trace"
+	"github.com/seniorGolang/gokit/utils"
+)
+
+// ServerTracing starts a server span for each call, batch items included.
+// The parent span is extracted from the request headers, which are also
+// stored in the context, so clients called by endpoints propagate them.
+func ServerTracing() ServerOption {
+	return func(s *Server) { s.tracing = true }
+}
+
+// startSpan starts the server span of the call as a child of the span
+// propagated in the headers stored in the context.
+func startSpan(ctx context.Context, req Request) (context.Context, opentracing.Span) {
+
+	headers := make(map[string]string)
+
+	for key, value := range utils.HeadersFromContext(ctx) {
+		headers[key] = fmt.Sprint(value)
+	}
+
+	span := trace.MakeSpan(req.Method, headers)
+
+	ext.SpanKindRPCServer.Set(span)
+	span.SetTag("jsonrpc.method", req.Method)
+
+	if req.ID != nil {
+		span.SetTag("jsonrpc.id", strings.Trim(req.ID.key(), `"`))
+	}
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return trace.InjectSpan(ctx), span
+}
+
+// finishServerSpan records the outcome of the call and finishes its span.
+func finishServerSpan(span opentracing.Span, resp *Response, started time.Time) {
+
+	if resp != nil && resp.Error != nil {
+		ext.Error.Set(span, true)
+		span.SetTag("jsonrpc.error_code", resp.Error.Code)
+	}
+
+	span.SetTag("jsonrpc.latency_ms", float64(time.Since(started))/float64(time.Millisecond))
+	span.Finish()
+}
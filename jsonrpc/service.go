@@ -0,0 +1,103 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// MakeEndpointCodecMap builds an EndpointCodecMap from exported methods of svc
+// of the form
+//
+//	func(ctx context.Context, request Req) (response Resp, err error)
+//
+// Methods are registered with lowerCamelCase names of Go methods, prefixed
+// with namespace and a dot if namespace is not empty, e.g. "user.getByID".
+// Params are decoded into Req both by-name and by-position, Resp is encoded as JSON.
+// Methods of other forms are skipped.
+func MakeEndpointCodecMap(namespace string, svc interface{}) (ecm EndpointCodecMap, err error) {
+
+	svcValue := reflect.ValueOf(svc)
+	svcType := svcValue.Type()
+
+	ecm = make(EndpointCodecMap)
+
+	for i := 0; i < svcType.NumMethod(); i++ {
+
+		method := svcType.Method(i)
+
+		if method.PkgPath != "" || !isEndpointMethod(method.Type) {
+			continue
+		}
+
+		name := methodName(method.Name)
+
+		if namespace != "" {
+			name = namespace + "." + name
+		}
+		ecm[name] = makeEndpointCodec(svcValue.Method(i))
+	}
+
+	if len(ecm) == 0 {
+		return nil, fmt.Errorf("%s has no methods of form func(context.Context, Req) (Resp, error)", svcType)
+	}
+	return
+}
+
+// isEndpointMethod checks the method type, receiver included.
+func isEndpointMethod(t reflect.Type) bool {
+	return t.NumIn() == 3 && t.In(1) == contextType &&
+		t.NumOut() == 2 && t.Out(1) == errorType
+}
+
+func makeEndpointCodec(method reflect.Value) EndpointCodec {
+
+	reqType := method.Type().In(1)
+
+	return EndpointCodec{
+
+		Endpoint: func(ctx context.Context, request interface{}) (response interface{}, err error) {
+
+			reqValue := reflect.ValueOf(request)
+
+			if !reqValue.IsValid() {
+				reqValue = reflect.Zero(reqType)
+			}
+
+			out := method.Call([]reflect.Value{reflect.ValueOf(ctx), reqValue})
+
+			if errValue := out[1].Interface(); errValue != nil {
+				err = errValue.(error)
+			}
+			return out[0].Interface(), err
+		},
+
+		Decode: makeParamsDecoder(reqType),
+
+		Encode: func(ctx context.Context, response interface{}) (json.RawMessage, error) {
+			return CodecFromContext(ctx).Marshal(response)
+		},
+
+		Params: reflect.Zero(reqType).Interface(),
+		Result: reflect.Zero(method.Type().Out(0)).Interface(),
+	}
+}
+
+// methodName converts Go method name to lowerCamelCase.
+func methodName(name string) string {
+
+	r, size := utf8.DecodeRuneInString(name)
+
+	if r == utf8.RuneError {
+		return name
+	}
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,393 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/seniorGolang/gokit/utils"
+)
+
+const wsConnKey contextKey = "wsConn"
+
+type contextKey string
+
+// DefaultWSConcurrency is the default limit of requests executed
+// concurrently for a single WebSocket connection.
+const DefaultWSConcurrency = 16
+
+// DefaultWSPongWait is the default time to wait for a message or a pong
+// of the client before the connection is closed.
+const DefaultWSPongWait = time.Minute
+
+// ErrConnClosed is returned on writes to the closed WebSocket connection.
+var ErrConnClosed = errors.New("connection is closed")
+
+// WSServer serves JSON RPC over WebSocket connections with the endpoints
+// and options of the Server. Requests of a connection are executed
+// concurrently up to the limit set by WSConcurrency, responses are sent
+// back as they are ready.
+type WSServer struct {
+	server       *Server
+	upgrader     websocket.Upgrader
+	writeTimeout time.Duration
+	pongWait     time.Duration
+	concurrency  int
+
+	lock   sync.RWMutex
+	groups map[string]map[*WSConn]struct{}
+}
+
+// WSConn is a WebSocket connection of a client.
+type WSConn struct {
+	ws   *WSServer
+	conn *websocket.Conn
+
+	lock   sync.Mutex
+	closed bool
+	groups map[string]struct{}
+}
+
+// WSOption sets an optional parameter for WebSocket servers.
+type WSOption func(*WSServer)
+
+// WSUpgrader sets the upgrader used for incoming connections,
+// e.g. to check the origin of requests.
+func WSUpgrader(upgrader websocket.Upgrader) WSOption {
+	return func(ws *WSServer) { ws.upgrader = upgrader }
+}
+
+// WSWriteTimeout limits the time of writing a single message to a connection.
+func WSWriteTimeout(timeout time.Duration) WSOption {
+	return func(ws *WSServer) { ws.writeTimeout = timeout }
+}
+
+// WSPongWait closes connections of clients which send neither messages nor
+// pongs within wait, e.g. clients gone without closing the connection.
+// Clients are pinged every 9/10 of wait. By default, DefaultWSPongWait
+// is used, a non-positive wait disables pings and read deadlines.
+func WSPongWait(wait time.Duration) WSOption {
+	return func(ws *WSServer) { ws.pongWait = wait }
+}
+
+// WSConcurrency limits the number of messages executed concurrently for
+// a single connection, reading of the connection waits once the limit is
+// reached. By default, DefaultWSConcurrency is used.
+func WSConcurrency(max int) WSOption {
+	return func(ws *WSServer) {
+		if max > 0 {
+			ws.concurrency = max
+		}
+	}
+}
+
+// NewWSServer constructs a WebSocket transport for the server.
+func NewWSServer(server *Server, options ...WSOption) *WSServer {
+
+	ws := &WSServer{
+		server:       server,
+		writeTimeout: time.Second * 10,
+		pongWait:     DefaultWSPongWait,
+		concurrency:  DefaultWSConcurrency,
+		groups:       make(map[string]map[*WSConn]struct{}),
+	}
+
+	for _, option := range options {
+		option(ws)
+	}
+	return ws
+}
+
+// ConnFromContext returns the WebSocket connection of the call,
+// so endpoints can subscribe it to groups or push notifications to it.
+func ConnFromContext(ctx context.Context) (conn *WSConn, found bool) {
+	conn, found = ctx.Value(wsConnKey).(*WSConn)
+	return
+}
+
+// ServeHTTP implements http.Handler. It upgrades the request to a WebSocket
+// connection and serves it until the connection is closed.
+func (ws *WSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := ws.upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		log.WithError(err).Error("websocket upgrade error")
+		return
+	}
+
+	c := &WSConn{
+		ws:     ws,
+		conn:   conn,
+		groups: make(map[string]struct{}),
+	}
+
+	defer c.Close()
+
+	if ws.server.maxBodySize > 0 {
+		conn.SetReadLimit(ws.server.maxBodySize)
+	}
+
+	if ws.pongWait > 0 {
+
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(ws.pongWait))
+		})
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go c.ping(ws.pongWait-ws.pongWait/10, stop)
+	}
+
+	ctx := context.WithValue(r.Context(), wsConnKey, c)
+
+	if ws.server.tracing {
+		ctx = utils.HttpToContext(ctx, r)
+	}
+
+	for _, f := range ws.server.before {
+		ctx = f(ctx, r)
+	}
+
+	ctx = ws.server.authenticate(ctx, r.Header.Get("Authorization"), r.Header.Get(ownerHeader))
+	ctx = context.WithValue(ctx, codecKey, ws.codec())
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	inFlight := make(chan struct{}, ws.concurrency)
+
+	for {
+
+		// the deadline is extended by messages and pongs, waiting for
+		// running requests doesn't count
+		if ws.pongWait > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(ws.pongWait))
+		}
+
+		_, data, err := conn.ReadMessage()
+
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.WithError(err).Debug("websocket read error")
+			}
+			return
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			c.serveMessage(ctx, data)
+		}()
+	}
+}
+
+// ping pings the client every interval until stop is closed.
+func (c *WSConn) ping(interval time.Duration, stop <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// control messages may be written concurrently with send
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveMessage executes requests of the message and writes responses back.
+func (c *WSConn) serveMessage(ctx context.Context, data []byte) {
+
+	batch, respList, err := c.ws.server.serveBody(ctx, "", data)
+
+	if err != nil {
+		respList = append(respList, bodyError(err))
+	}
+
+	if data, err = encodeResponses(c.ws.codec(), batch, respList); err == nil && len(data) > 0 {
+		err = c.send(data)
+	}
+
+	if err != nil {
+		log.WithError(err).Debug("websocket write error")
+	}
+}
+
+// Notify pushes the notification of the method to the client.
+func (c *WSConn) Notify(method string, params interface{}) (err error) {
+
+	var rawParams json.RawMessage
+
+	if rawParams, err = c.ws.paramsCodec().Marshal(params); err != nil {
+		return
+	}
+
+	return c.write(Request{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  rawParams,
+	})
+}
+
+// Subscribe adds the connection to the group. Connections leave all
+// groups when closed.
+func (c *WSConn) Subscribe(group string) {
+
+	c.ws.lock.Lock()
+	defer c.ws.lock.Unlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	if c.ws.groups[group] == nil {
+		c.ws.groups[group] = make(map[*WSConn]struct{})
+	}
+
+	c.groups[group] = struct{}{}
+	c.ws.groups[group][c] = struct{}{}
+}
+
+// Unsubscribe removes the connection from the group.
+func (c *WSConn) Unsubscribe(group string) {
+
+	c.ws.lock.Lock()
+	defer c.ws.lock.Unlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ws.leave(c, group)
+}
+
+// Close closes the connection.
+func (c *WSConn) Close() error {
+
+	c.ws.lock.Lock()
+	defer c.ws.lock.Unlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	for group := range c.groups {
+		c.ws.leave(c, group)
+	}
+	return c.conn.Close()
+}
+
+// write encodes the message with the codec of the server and sends it.
+func (c *WSConn) write(message interface{}) error {
+
+	data, err := c.ws.codec().Marshal(message)
+
+	if err != nil {
+		return err
+	}
+	return c.send(data)
+}
+
+// send sends the encoded message to the client, writes are serialized since
+// the connection supports only one concurrent writer.
+func (c *WSConn) send(data []byte) error {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return ErrConnClosed
+	}
+
+	if c.ws.writeTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.ws.writeTimeout))
+	}
+
+	messageType := websocket.TextMessage
+
+	if _, binary := c.ws.codec().(BinaryCodec); binary {
+		messageType = websocket.BinaryMessage
+	}
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// Broadcast pushes the notification of the method to all connections
+// subscribed to the group.
+func (ws *WSServer) Broadcast(group, method string, params interface{}) (err error) {
+
+	var rawParams json.RawMessage
+
+	if rawParams, err = ws.paramsCodec().Marshal(params); err != nil {
+		return
+	}
+
+	notification := Request{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  rawParams,
+	}
+
+	ws.lock.RLock()
+	conns := make([]*WSConn, 0, len(ws.groups[group]))
+	for c := range ws.groups[group] {
+		conns = append(conns, c)
+	}
+	ws.lock.RUnlock()
+
+	for _, c := range conns {
+		if err := c.write(notification); err != nil && err != ErrConnClosed {
+			log.WithError(err).WithField("group", group).Debug("broadcast error")
+		}
+	}
+	return nil
+}
+
+// codec returns the codec of messages, which is the first codec of the server.
+func (ws *WSServer) codec() Codec {
+	return ws.server.codecs[0]
+}
+
+// paramsCodec returns the codec of params of notifications.
+func (ws *WSServer) paramsCodec() Codec {
+
+	if binary, ok := ws.codec().(BinaryCodec); ok {
+		return binary.JSON()
+	}
+	return ws.codec()
+}
+
+// leave removes the connection from the group, both locks must be held.
+func (ws *WSServer) leave(c *WSConn, group string) {
+
+	delete(c.groups, group)
+
+	if conns, found := ws.groups[group]; found {
+		if delete(conns, c); len(conns) == 0 {
+			delete(ws.groups, group)
+		}
+	}
+}
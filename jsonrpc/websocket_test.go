@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSPongWait(t *testing.T) {
+
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(NewWSServer(s, WSPongWait(100*time.Millisecond)))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// the client reading the connection answers pings and stays connected
+	ws, err := DialWS(context.Background(), url, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	time.Sleep(300 * time.Millisecond)
+
+	if _, err = NewClient(srv.URL, "echo", ClientWebSocket(ws)).Endpoint()(context.Background(), testParams{A: 1}); err != nil {
+		t.Errorf("idle client is disconnected: %v", err)
+	}
+
+	// the silent client doesn't answer pings and is disconnected
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	time.Sleep(300 * time.Millisecond)
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	if netErr, timeout := err.(interface{ Timeout() bool }); timeout && netErr.Timeout() {
+		t.Error("silent client is not disconnected")
+	}
+}
@@ -15,11 +15,16 @@ var (
 	log = logger.Log.WithField("module", "httpServer")
 )
 
-func StartFastHttpServer(handler http.Handler, address string) (srv *fasthttp.Server) {
+func StartFastHttpServer(handler http.Handler, address string, options ...HandlerOption) (srv *fasthttp.Server) {
+
+	const maxRequestBodySize = 200 * 1024 * 1024
+
+	// decompressed bodies get the limit of compressed ones unless it's set
+	options = append([]HandlerOption{MaxDecompressedSize(maxRequestBodySize)}, options...)
 
 	srv = &fasthttp.Server{
-		MaxRequestBodySize: 200 * 1024 * 1024,
-		Handler:            NewFastHTTPHandler(handler),
+		MaxRequestBodySize: maxRequestBodySize,
+		Handler:            NewFastHTTPHandler(handler, options...),
 	}
 
 	go func() {
@@ -4,11 +4,57 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/valyala/fasthttp"
+
+	"github.com/seniorGolang/gokit/utils"
 )
 
-func NewFastHTTPHandler(h http.Handler) fasthttp.RequestHandler {
+// DefaultCompressionThreshold is the minimal size in bytes of responses
+// compressed by handlers of NewFastHTTPHandler.
+const DefaultCompressionThreshold = 1024
+
+// HandlerOption sets an optional parameter for handlers of NewFastHTTPHandler.
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	compressionThreshold int
+	maxDecompressedSize  int64
+}
+
+// CompressionThreshold sets the minimal size in bytes of responses to be
+// compressed with the coding accepted by the client. Streamed responses are
+// compressed regardless of the size. Negative size disables compression of
+// responses, compressed requests are accepted anyway.
+func CompressionThreshold(size int) HandlerOption {
+	return func(o *handlerOptions) { o.compressionThreshold = size }
+}
+
+// MaxDecompressedSize limits the size in bytes of decompressed request bodies,
+// reading more fails with utils.ErrTooLarge. It's utils.DefaultMaxDecompressedSize
+// by default, since MaxRequestBodySize of the server limits compressed bodies only.
+func MaxDecompressedSize(size int64) HandlerOption {
+	return func(o *handlerOptions) {
+		if size > 0 {
+			o.maxDecompressedSize = size
+		}
+	}
+}
+
+// NewFastHTTPHandler adapts the net/http handler to fasthttp. Requests with
+// gzip, deflate or brotli Content-Encoding are decompressed, responses are
+// compressed as negotiated by Accept-Encoding.
+func NewFastHTTPHandler(h http.Handler, options ...HandlerOption) fasthttp.RequestHandler {
+
+	opts := handlerOptions{
+		compressionThreshold: DefaultCompressionThreshold,
+		maxDecompressedSize:  utils.DefaultMaxDecompressedSize,
+	}
+
+	for _, option := range options {
+		option(&opts)
+	}
 
 	return func(ctx *fasthttp.RequestCtx) {
 
@@ -24,6 +70,8 @@ func NewFastHTTPHandler(h http.Handler) fasthttp.RequestHandler {
 		r.Host = string(ctx.Host())
 		r.RemoteAddr = ctx.RemoteAddr().String()
 
+		var contentEncoding string
+
 		hdr := make(http.Header)
 		ctx.Request.Header.VisitAll(func(k, v []byte) {
 			sk := string(k)
@@ -31,12 +79,39 @@ func NewFastHTTPHandler(h http.Handler) fasthttp.RequestHandler {
 			switch sk {
 			case "Transfer-Encoding":
 				r.TransferEncoding = append(r.TransferEncoding, sv)
+			case "Content-Encoding":
+				contentEncoding = strings.ToLower(strings.TrimSpace(sv))
 			default:
 				hdr.Set(sk, sv)
 			}
 		})
 		r.Header = hdr
 		r.Body = &netHTTPBody{body}
+
+		if contentEncoding != "" && contentEncoding != "identity" {
+
+			if !utils.SupportedEncoding(contentEncoding) {
+				ctx.Error("Unsupported Media Type", fasthttp.StatusUnsupportedMediaType)
+				return
+			}
+
+			reader, err := utils.NewDecompressor(contentEncoding, r.Body)
+
+			if err != nil {
+				ctx.Error("Bad Request", fasthttp.StatusBadRequest)
+				return
+			}
+
+			// the handler reads the decompressed body, so limits of the
+			// handler apply to the decompressed size as well
+			r.Body = decompressedBody{
+				Reader: utils.LimitReader(reader, opts.maxDecompressedSize),
+				Closer: reader,
+			}
+			r.ContentLength = -1
+			hdr.Del("Content-Length")
+		}
+
 		rURL, err := url.ParseRequestURI(r.RequestURI)
 		if err != nil {
 			ctx.Logger().Printf("cannot parse requestURI %q: %s", r.RequestURI, err)
@@ -45,8 +120,27 @@ func NewFastHTTPHandler(h http.Handler) fasthttp.RequestHandler {
 		}
 		r.URL = rURL
 
-		var w netHTTPResponseWriter
-		h.ServeHTTP(&w, &r)
+		w := netHTTPResponseWriter{flushed: make(chan struct{})}
+
+		if opts.compressionThreshold >= 0 {
+			w.encoding = utils.NegotiateEncoding(string(ctx.Request.Header.Peek("Accept-Encoding")))
+		}
+
+		done := make(chan struct{})
+
+		// the handler runs until it returns or flushes the response,
+		// flushed responses are streamed while the handler writes them
+		go func() {
+			defer close(done)
+			h.ServeHTTP(&w, &r)
+			w.finish()
+		}()
+
+		select {
+		case <-done:
+			w.compress(opts.compressionThreshold)
+		case <-w.flushed:
+		}
 
 		ctx.SetStatusCode(w.StatusCode())
 		for k, vv := range w.Header() {
@@ -54,8 +148,17 @@ func NewFastHTTPHandler(h http.Handler) fasthttp.RequestHandler {
 				ctx.Response.Header.Set(k, v)
 			}
 		}
-		ctx.SetConnectionClose()
-		ctx.Write(w.body)
+
+		if w.stream == nil {
+			ctx.Write(w.body)
+			return
+		}
+
+		// the pipe is closed by fasthttp once the response is sent or
+		// dropped, so the handler never blocks on a gone client; the next
+		// request of the connection is read only after the handler finishes
+		// the stream, so the connection is kept alive
+		ctx.SetBodyStream(w.stream, -1)
 	}
 }
 
@@ -81,6 +184,18 @@ type netHTTPResponseWriter struct {
 	statusCode int
 	h          http.Header
 	body       []byte
+
+	// flushed is closed on the first Flush, then flushed parts of the
+	// body are written to pipe and read from stream by fasthttp
+	flushed chan struct{}
+	stream  *io.PipeReader
+	pipe    *io.PipeWriter
+	err     error
+
+	// encoding is the content coding accepted by the client,
+	// streamed parts are compressed by compressor
+	encoding   string
+	compressor utils.Compressor
 }
 
 func (w *netHTTPResponseWriter) StatusCode() int {
@@ -102,6 +217,85 @@ func (w *netHTTPResponseWriter) WriteHeader(statusCode int) {
 }
 
 func (w *netHTTPResponseWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
 	w.body = append(w.body, p...)
 	return len(p), nil
 }
+
+// Flush implements http.Flusher. The first flush switches the response to
+// streaming, headers can't be changed after it.
+func (w *netHTTPResponseWriter) Flush() {
+
+	if w.stream == nil {
+		w.stream, w.pipe = io.Pipe()
+		if w.compressible() {
+			w.compressor, _ = utils.NewCompressor(w.encoding, w.pipe)
+			w.setEncoding()
+		}
+		close(w.flushed)
+	}
+
+	if len(w.body) == 0 || w.err != nil {
+		return
+	}
+
+	if w.compressor == nil {
+		_, w.err = w.pipe.Write(w.body)
+	} else if _, w.err = w.compressor.Write(w.body); w.err == nil {
+		w.err = w.compressor.Flush()
+	}
+	w.body = w.body[:0]
+}
+
+// compress compresses the buffered body of the size above the threshold.
+func (w *netHTTPResponseWriter) compress(threshold int) {
+
+	if !w.compressible() || len(w.body) < threshold {
+		return
+	}
+
+	compressed, err := utils.Compress(w.encoding, w.body)
+
+	if err != nil {
+		return
+	}
+
+	w.body = compressed
+	w.setEncoding()
+}
+
+// compressible reports whether the response may be compressed, i.e. the
+// client accepts a coding and the handler did not encode the body itself.
+func (w *netHTTPResponseWriter) compressible() bool {
+	return w.encoding != "" && w.Header().Get("Content-Encoding") == ""
+}
+
+func (w *netHTTPResponseWriter) setEncoding() {
+
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+}
+
+// finish sends the rest of the streamed response.
+func (w *netHTTPResponseWriter) finish() {
+
+	if w.stream == nil {
+		return
+	}
+
+	w.Flush()
+
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+	_ = w.pipe.Close()
+}
+
+// decompressedBody reads the limited decompressed body and closes the decompressor.
+type decompressedBody struct {
+	io.Reader
+	io.Closer
+}
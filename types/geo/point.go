@@ -16,11 +16,13 @@ var (
 	ErrGeoCoords = errors.New("coordinates has wrong format for point type")
 )
 
+// Point is a geographic point. gkg struct tags repeat @gkg comments of fields,
+// since comments are not available at run time.
 type Point struct {
 	// @gkg example=43.116418 desc="latitude"
-	Lat float64 `json:"lat" fake:"latitude"`
+	Lat float64 `json:"lat" fake:"latitude" gkg:"example=43.116418 desc=\"latitude\""`
 	// @gkg example=131.882475 desc="longitude"
-	Lon float64 `json:"lon" fake:"longitude"`
+	Lon float64 `json:"lon" fake:"longitude" gkg:"example=131.882475 desc=\"longitude\""`
 }
 
 func (point *Point) GetReduced(precision float64) *Point {
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings of HTTP bodies, the deflate coding is the zlib format as
+// defined by HTTP.
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// AcceptEncoding is the Accept-Encoding header value of supported codings.
+const AcceptEncoding = "br, gzip, deflate"
+
+// DefaultMaxDecompressedSize limits the size of decompressed bodies unless
+// other limit is set, it protects servers from decompression bombs.
+const DefaultMaxDecompressedSize = 4 * 1024 * 1024
+
+// ErrTooLarge is returned by readers of LimitReader once the limit is exceeded.
+var ErrTooLarge = errors.New("body exceeds the size limit")
+
+// encodings are supported codings in order of preference.
+var encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+
+// Compressor is a writer compressing data written to the underlying writer.
+// Flush writes pending data, so it can be sent before the writer is closed.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressors = map[string]*sync.Pool{
+	EncodingBrotli:  {New: func() interface{} { return brotli.NewWriter(nil) }},
+	EncodingGzip:    {New: func() interface{} { return gzip.NewWriter(nil) }},
+	EncodingDeflate: {New: func() interface{} { return zlib.NewWriter(nil) }},
+}
+
+// SupportedEncoding reports whether the content coding is supported.
+func SupportedEncoding(encoding string) bool {
+	_, found := compressors[encoding]
+	return found
+}
+
+// NegotiateEncoding returns the supported content coding preferred by the
+// Accept-Encoding header value, or an empty string if none is acceptable.
+func NegotiateEncoding(acceptEncoding string) (encoding string) {
+
+	var quality float64
+	var wildcard float64 = -1
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+
+		for _, param := range params[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			qualities[name] = q
+		}
+	}
+
+	for _, candidate := range encodings {
+
+		q, found := qualities[candidate]
+
+		if !found {
+			q = wildcard
+		}
+
+		if q > quality {
+			encoding, quality = candidate, q
+		}
+	}
+	return
+}
+
+// NewCompressor returns the writer compressing data to w with the content coding.
+func NewCompressor(encoding string, w io.Writer) (Compressor, error) {
+
+	pool, found := compressors[encoding]
+
+	if !found {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	compressor := pool.New().(Compressor)
+	compressor.Reset(w)
+	return compressor, nil
+}
+
+// Compress compresses data with the content coding.
+func Compress(encoding string, data []byte) ([]byte, error) {
+
+	pool, found := compressors[encoding]
+
+	if !found {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	var buf bytes.Buffer
+
+	compressor := pool.Get().(Compressor)
+	defer pool.Put(compressor)
+
+	compressor.Reset(&buf)
+
+	if _, err := compressor.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewDecompressor returns the reader decompressing data of r with the content coding.
+func NewDecompressor(encoding string, r io.Reader) (io.ReadCloser, error) {
+
+	switch encoding {
+
+	case EncodingBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+
+	case EncodingGzip:
+		return gzip.NewReader(r)
+
+	case EncodingDeflate:
+		return zlib.NewReader(r)
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// LimitReader returns the reader of r failing with ErrTooLarge once more
+// than n bytes are read, unlike io.LimitReader, which reports the end of data.
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitedReader{r: r, left: n}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+
+	if l.left < 0 {
+		return 0, ErrTooLarge
+	}
+
+	// one extra byte detects data over the limit
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+
+	n, err = l.r.Read(p)
+
+	if l.left -= int64(n); l.left < 0 {
+		return n + int(l.left), ErrTooLarge
+	}
+	return
+}
+
+// Decompress decompresses data with the content coding. Decompressed data
+// exceeding maxSize bytes is rejected, zero maxSize means no limit.
+func Decompress(encoding string, data []byte, maxSize int64) ([]byte, error) {
+
+	reader, err := NewDecompressor(encoding, bytes.NewReader(data))
+
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if maxSize <= 0 {
+		return ioutil.ReadAll(reader)
+	}
+
+	if data, err = ioutil.ReadAll(io.LimitReader(reader, maxSize+1)); err == nil && int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("decompressed body exceeds %d bytes", maxSize)
+	}
+	return data, err
+}
@@ -26,6 +26,14 @@ func GetOwnerUUID(ctx context.Context) (id uuid.UUID, err error) {
 	return uuid.FromString(GetOwnerId(ctx))
 }
 
+func SetOwnerId(ctx context.Context, id string) context.Context {
+
+	if id == "" {
+		return context.WithValue(ctx, ownerKey, nil)
+	}
+	return AddHeaderToContext(ctx, ownerKey, id)
+}
+
 func AddHeadersToContext(ctx context.Context, headers map[string]interface{}) context.Context {
 
 	for key, value := range headers {